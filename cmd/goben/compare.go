@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/pflag"
+
+	"github.com/udhos/goben/goben"
+)
+
+// exit codes of the compare command
+const (
+	compareOk         = 0
+	compareRegression = 1
+	compareError      = 2
+)
+
+// compare implements: goben compare [flags] baseline current
+func compare(args []string) int {
+	flagset := pflag.NewFlagSet("compare", pflag.ContinueOnError)
+	flagset.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: goben compare [flags] baseline.yaml|csv current.yaml|csv\n")
+		fmt.Fprintf(os.Stderr, "compares mean and percentiles of the interval rates of two exports, exits %d on regression\n", compareRegression)
+		flagset.PrintDefaults()
+	}
+	tolerance := flagset.Float64("tolerance", 5, "allowed slowdown in percent before a metric is a regression")
+	unit := flagset.String("unit", "Mbps", "rate unit: bps, Kbps, Mbps, Gbps, Tbps, bytes/s, KB/s, MB/s, GB/s or auto")
+	if err := flagset.Parse(args); err != nil {
+		return compareError
+	}
+	if flagset.NArg() != 2 || *tolerance < 0 {
+		flagset.Usage()
+		return compareError
+	}
+
+	baseline, errBase := goben.ReadExport(flagset.Arg(0))
+	if errBase != nil {
+		fmt.Fprintf(os.Stderr, "compare: baseline: %v\n", errBase)
+		return compareError
+	}
+	current, errCur := goben.ReadExport(flagset.Arg(1))
+	if errCur != nil {
+		fmt.Fprintf(os.Stderr, "compare: current: %v\n", errCur)
+		return compareError
+	}
+
+	comparisons := goben.CompareExports(baseline, current, *tolerance/100)
+	if len(comparisons) == 0 {
+		fmt.Fprintf(os.Stderr, "compare: baseline has no data: %s\n", flagset.Arg(0))
+		return compareError
+	}
+	if err := goben.WriteComparisonTable(os.Stdout, comparisons, *unit); err != nil {
+		fmt.Fprintf(os.Stderr, "compare: %v\n", err)
+		return compareError
+	}
+
+	var regressions int
+	for _, c := range comparisons {
+		if c.Regression {
+			regressions++
+		}
+	}
+	if regressions > 0 {
+		fmt.Printf("REGRESSION: %d of %d metrics slower than baseline by more than %v%%\n", regressions, len(comparisons), *tolerance)
+		return compareRegression
+	}
+	fmt.Printf("OK: no metric slower than baseline by more than %v%%\n", *tolerance)
+	return compareOk
+}
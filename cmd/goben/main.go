@@ -3,6 +3,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -16,17 +17,55 @@ import (
 	"github.com/udhos/goben/goben"
 )
 
+// exitThresholdFailed is the exit status when a --minMbps, --maxLoss or --maxRTT
+// threshold fails, distinct from errors (1) and bad usage (2).
+const exitThresholdFailed = 3
+
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		os.Exit(compare(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(render(os.Args[2:]))
+	}
+
 	app := goben.Config{}
 
 	app.AssignFlags(pflag.CommandLine)
 	pflag.Parse()
 
-	log.Print("goben version " + goben.Version + " runtime " + runtime.Version() + " GOMAXPROCS=" + strconv.Itoa(runtime.GOMAXPROCS(0)) + " OS=" + runtime.GOOS + " arch=" + runtime.GOARCH)
-	log.Printf("connections=%d defaultPort=%s listeners=%q hosts=%q",
-		app.Connections, app.DefaultPort, app.Listeners, app.Hosts)
-	log.Printf("reportInterval=%s totalDuration=%s", app.Opt.ReportInterval, app.Opt.TotalDuration)
+	if errEnv := goben.LoadEnv(pflag.CommandLine); errEnv != nil {
+		log.Fatal(errEnv)
+	}
+
+	if errConfig := app.LoadConfigFile(pflag.CommandLine); errConfig != nil {
+		log.Fatal(errConfig)
+	}
+
+	if app.DumpConfig {
+		if errValidate := goben.ValidateAndUpdateConfig(&app); errValidate != nil {
+			log.Fatal(errValidate) // do not dump a config that would fail to run
+		}
+		if errDump := app.WriteConfig(os.Stdout, pflag.CommandLine); errDump != nil {
+			log.Fatal(errDump)
+		}
+		return
+	}
+
+	app.SetOutput(os.Stdout) // before LogLevel, which depends on --live on a terminal
+
+	logger, errLogger := goben.NewLogger(os.Stderr, app.LogFormat, app.LogLevel())
+	if errLogger != nil {
+		log.Fatal(errLogger)
+	}
+	app.SetLogger(logger)
+
+	logger.Info("goben version " + goben.Version + " runtime " + runtime.Version() + " GOMAXPROCS=" + strconv.Itoa(runtime.GOMAXPROCS(0)) + " OS=" + runtime.GOOS + " arch=" + runtime.GOARCH)
+	logger.Debug(fmt.Sprintf("connections=%d defaultPort=%s listeners=%q hosts=%q",
+		app.Connections, app.DefaultPort, app.Listeners, app.Hosts))
+	logger.Debug(fmt.Sprintf("reportInterval=%s totalDuration=%s", app.ReportInterval, app.TotalDuration))
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -35,17 +74,17 @@ func main() {
 
 	go func() {
 		sig := <-sigChan
-		log.Printf("Received signal: %v, gracefully shutting down...", sig)
+		logger.Info(fmt.Sprintf("Received signal: %v, gracefully shutting down...", sig))
 		cancel()
 	}()
 
 	if len(app.Hosts) == 0 {
-		log.Printf("server mode (use -hosts to switch to client mode)")
+		logger.Info("server mode (use -hosts to switch to client mode)")
 
 		var wg sync.WaitGroup
 		listenSuccess := goben.Serve(ctx, &app, &wg)
 		if !listenSuccess {
-			log.Println("server failed to listen")
+			logger.Error("server failed to listen")
 			cancel()
 			return
 		}
@@ -62,9 +101,47 @@ func main() {
 		proto = "tcp"
 	}
 
-	log.Printf("client mode, %s protocol", proto)
+	logger.Info("client mode, " + proto + " protocol")
+
+	if app.Scenarios != "" {
+		results, errScenarios := goben.RunScenarios(ctx, &app, pflag.CommandLine)
+		if errScenarios != nil {
+			logger.Error(errScenarios.Error())
+			os.Exit(1)
+		}
+		passed := true
+		for _, r := range results {
+			if r.Err != nil {
+				os.Exit(1)
+			}
+			passed = passed && r.Stats.Passed()
+		}
+		if !passed {
+			os.Exit(exitThresholdFailed)
+		}
+		return
+	}
+
+	if app.Repeat > 1 {
+		repeat, err := goben.OpenRepeat(ctx, &app)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+		for _, r := range repeat.Runs {
+			if !r.Passed() {
+				os.Exit(exitThresholdFailed)
+			}
+		}
+		return
+	}
 
-	if _, err := goben.Open(ctx, &app); err != nil {
-		log.Fatalf("Failed to open connection: %v", err)
+	stats, err := goben.Open(ctx, &app)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Failed to open connection: %v", err))
+		os.Exit(1)
+	}
+	if !stats.Passed() {
+		os.Exit(exitThresholdFailed)
 	}
 }
@@ -1,8 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -154,6 +163,46 @@ func TestEndToEndTCP(t *testing.T) {
 	assert.Greater(t, clientStats.WriteBytes, int64(100))
 }
 
+func TestEndToEndLegacyPlots(t *testing.T) {
+
+	// a client config of the legacy API, without SetOutput: ASCII plots go to stdout
+	client := goben.NewDefaultConfig()
+	client.Hosts = goben.HostList{"127.0.0.1:18455"}
+	client.TLS = false
+	client.ReportInterval = "200ms"
+	client.TotalDuration = "1s"
+	client.Export = []string{"ascii"}
+
+	// a server config
+	server := goben.NewDefaultConfig()
+	server.Listeners = goben.HostList{"127.0.0.1:18455"}
+	server.TLS = false
+	server.UDP = false
+
+	// launch server
+	var wg sync.WaitGroup
+	listenSuccess := goben.Serve(context.Background(), server, &wg)
+	if !listenSuccess {
+		t.Error("server failed to listen")
+	}
+
+	stdout, errCreate := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if errCreate != nil {
+		t.Fatal(errCreate)
+	}
+	defer stdout.Close()
+	saved := os.Stdout
+	os.Stdout = stdout
+	_, err := goben.Open(context.Background(), client)
+	os.Stdout = saved
+	assert.NoError(t, err)
+
+	plots, errRead := os.ReadFile(stdout.Name())
+	assert.NoError(t, errRead)
+	assert.Contains(t, string(plots), "Input Mbps: 127.0.0.1:18455 Connection 0")
+	assert.Contains(t, string(plots), "Output Mbps: 127.0.0.1:18455 Connection 0")
+}
+
 func TestEndToEndTCPFallback(t *testing.T) {
 
 	// a client config
@@ -191,3 +240,554 @@ func TestEndToEndTCPFallback(t *testing.T) {
 	assert.Greater(t, clientStats.ReadBytes, int64(100))
 	assert.Greater(t, clientStats.WriteBytes, int64(100))
 }
+
+func TestInvalidLocalAddr(t *testing.T) {
+
+	// a client config with an unresolvable local address
+	client := goben.NewDefaultConfig()
+	client.Hosts = goben.HostList{"127.0.0.1:18448@bad:local:addr"}
+	client.TLS = false
+	client.TCP = true
+	client.UDP = false
+
+	assert.Error(t, goben.ValidateAndUpdateConfig(client))
+
+	_, err := goben.Open(context.Background(), client)
+	assert.Error(t, err)
+}
+
+func TestEndToEndSyncStart(t *testing.T) {
+
+	// a client config with several connections ramped up on a common schedule
+	client := goben.NewDefaultConfig()
+	client.Hosts = goben.HostList{"127.0.0.1:18449"}
+	client.TLS = false
+	client.TCP = true
+	client.UDP = false
+	client.ReportInterval = "1s"
+	client.TotalDuration = "2s"
+	client.RampInterval = "200ms"
+	client.Connections = 3
+	client.Opt.SyncStart = true
+
+	// a server config
+	server := goben.NewDefaultConfig()
+	server.Listeners = goben.HostList{"127.0.0.1:18449"}
+	server.TLS = false
+	server.TCP = true
+	server.UDP = false
+
+	// launch server
+	var wg sync.WaitGroup
+	listenSuccess := goben.Serve(context.Background(), server, &wg)
+	if !listenSuccess {
+		t.Error("server failed to listen")
+	}
+
+	// launch client: all connections stop together at the common deadline
+	begin := time.Now()
+	clientStats, err := goben.Open(context.Background(), client)
+	elapsed := time.Since(begin)
+	assert.NoError(t, err)
+	assert.Less(t, elapsed, 3*time.Second)
+	assert.Greater(t, clientStats.ReadMbps, float64(100))
+	assert.Greater(t, clientStats.WriteMbps, float64(100))
+
+	// connections start together at their offsets of the common schedule:
+	// their first interval, less the ramp offset, ends at the same time
+	if !assert.Len(t, clientStats.Hosts, 1) || !assert.Len(t, clientStats.Hosts[0].Connections, 3) {
+		return
+	}
+	var starts []time.Time
+	for _, cs := range clientStats.Hosts[0].Connections {
+		for _, dir := range []goben.DirStats{cs.Read, cs.Write} {
+			if !assert.NotEmpty(t, dir.Intervals.XValues, "connection %d", cs.Index) {
+				return
+			}
+			offset := time.Duration(cs.Index) * 200 * time.Millisecond
+			starts = append(starts, dir.Intervals.XValues[0].Add(-offset))
+		}
+	}
+	first, last := slices.MinFunc(starts, time.Time.Compare), slices.MaxFunc(starts, time.Time.Compare)
+	assert.Less(t, last.Sub(first), 100*time.Millisecond, "connections did not start on the common schedule: %v", starts)
+}
+
+func TestEndToEndBytesLimit(t *testing.T) {
+
+	// a client config bounded by bytes only
+	client := goben.NewDefaultConfig()
+	client.Hosts = goben.HostList{"127.0.0.1:18450"}
+	client.TLS = false
+	client.TCP = true
+	client.UDP = false
+	client.ReportInterval = "1s"
+	client.TotalDuration = "0"
+	client.Connections = 2
+	client.Opt.Bytes = 50000001
+	client.LimitTotal = true
+
+	// a server config
+	server := goben.NewDefaultConfig()
+	server.Listeners = goben.HostList{"127.0.0.1:18450"}
+	server.TLS = false
+	server.TCP = true
+	server.UDP = false
+
+	// launch server
+	var wg sync.WaitGroup
+	listenSuccess := goben.Serve(context.Background(), server, &wg)
+	if !listenSuccess {
+		t.Error("server failed to listen")
+	}
+
+	// launch client: exactly the requested bytes are transferred in each direction
+	clientStats, err := goben.Open(context.Background(), client)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(50000001), clientStats.ReadBytes)
+	assert.Equal(t, int64(50000001), clientStats.WriteBytes)
+	assert.Greater(t, clientStats.Elapsed, time.Duration(0))
+}
+
+func TestEndToEndUDPBytesLimit(t *testing.T) {
+
+	// a UDP server reporting its averages
+	averages := make(chan goben.Report, 10)
+	server := goben.NewServer([]string{"127.0.0.1:0"}, goben.WithUDP(),
+		goben.WithReportFunc(func(r goben.Report) {
+			if r.Kind == "average" {
+				averages <- r
+			}
+		}))
+	errStart := server.Start(context.Background())
+	if !assert.NoError(t, errStart) {
+		return
+	}
+	defer server.Close()
+
+	var host string
+	for _, a := range server.Addrs() {
+		if _, isUDP := a.(*net.UDPAddr); isUDP {
+			host = a.String()
+		}
+	}
+
+	// a client bounded by bytes only: the server finishes when the limit arrives
+	client := goben.NewClient([]string{host}, goben.WithUDP(), goben.WithPassiveServer(),
+		goben.WithDuration(0), goben.WithBytes(640000), goben.WithMaxSpeed(50))
+	_, err := client.Run(context.Background())
+	assert.NoError(t, err)
+
+	select {
+	case r := <-averages:
+		assert.Equal(t, "handleUDP", r.Label)
+	case <-time.After(2 * time.Second):
+		t.Errorf("UDP server did not finish at the byte limit")
+	}
+}
+
+func TestEndToEndUDPPassiveClientBytesLimit(t *testing.T) {
+
+	server := goben.NewServer([]string{"127.0.0.1:0"}, goben.WithUDP())
+	errStart := server.Start(context.Background())
+	if !assert.NoError(t, errStart) {
+		return
+	}
+	defer server.Close()
+
+	var host string
+	for _, a := range server.Addrs() {
+		if _, isUDP := a.(*net.UDPAddr); isUDP {
+			host = a.String()
+		}
+	}
+
+	// a receive-only UDP client cannot detect the end of the transfer: it runs until the deadline
+	client := goben.NewClient([]string{host}, goben.WithUDP(), goben.WithPassiveClient(),
+		goben.WithDuration(time.Second), goben.WithBytes(20000000), goben.WithMaxSpeed(50))
+	begin := time.Now()
+	result, err := client.Run(context.Background())
+	elapsed := time.Since(begin)
+	assert.NoError(t, err)
+	assert.Greater(t, elapsed, 900*time.Millisecond)
+	assert.Greater(t, result.ReadBytes, int64(0))
+
+	// without a duration, it would never finish
+	client = goben.NewClient([]string{host}, goben.WithUDP(), goben.WithPassiveClient(),
+		goben.WithDuration(0), goben.WithBytes(20000000))
+	_, err = client.Run(context.Background())
+	assert.Error(t, err)
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent log output.
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestEndToEndUDPVerify(t *testing.T) {
+
+	// a UDP server logging its verify result
+	var serverLog syncBuffer
+	server := goben.NewServer([]string{"127.0.0.1:0"}, goben.WithUDP(),
+		goben.WithLogger(slog.New(slog.NewTextHandler(&serverLog, nil))))
+	errStart := server.Start(context.Background())
+	if !assert.NoError(t, errStart) {
+		return
+	}
+	defer server.Close()
+
+	var host string
+	for _, a := range server.Addrs() {
+		if _, isUDP := a.(*net.UDPAddr); isUDP {
+			host = a.String()
+		}
+	}
+
+	// a client bounded by duration: it stops sending at the deadline, the server finishes on its own
+	client := goben.NewClient([]string{host}, goben.WithUDP(), goben.WithPassiveServer(),
+		goben.WithDuration(500*time.Millisecond), goben.WithMaxSpeed(50),
+		goben.WithConfig(func(c *goben.Config) { c.Opt.Verify = true }))
+	_, err := client.Run(context.Background())
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(serverLog.String(), "handleUDP: 0/0 verify:")
+	}, 2*time.Second, 50*time.Millisecond, "UDP server did not report its verify result: %s", serverLog.String())
+	assert.Contains(t, serverLog.String(), " 0 corrupted bytes")
+}
+
+func TestEndToEndUDPMaxLoss(t *testing.T) {
+
+	server := goben.NewServer([]string{"127.0.0.1:0"}, goben.WithUDP())
+	errStart := server.Start(context.Background())
+	if !assert.NoError(t, errStart) {
+		return
+	}
+	defer server.Close()
+
+	var host string
+	for _, a := range server.Addrs() {
+		if _, isUDP := a.(*net.UDPAddr); isUDP {
+			host = a.String()
+		}
+	}
+
+	// the server confirms sequence numbers, so loss is measured on its datagrams
+	client := goben.NewClient([]string{host}, goben.WithUDP(), goben.WithDuration(500*time.Millisecond),
+		goben.WithMaxSpeed(50), goben.WithThresholds(0, 50, 0))
+	result, err := client.Run(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Greater(t, result.ReceivedDatagrams, int64(0))
+	assert.True(t, result.Passed(), "%+v", result.Thresholds)
+}
+
+func TestEndToEndVerify(t *testing.T) {
+
+	// a client config verifying data integrity
+	client := goben.NewDefaultConfig()
+	client.Hosts = goben.HostList{"127.0.0.1:18451"}
+	client.TLS = false
+	client.TCP = true
+	client.UDP = false
+	client.ReportInterval = "1s"
+	client.TotalDuration = "1s"
+	client.Connections = 2
+	client.Opt.Verify = true
+
+	// a server config
+	server := goben.NewDefaultConfig()
+	server.Listeners = goben.HostList{"127.0.0.1:18451"}
+	server.TLS = false
+	server.TCP = true
+	server.UDP = false
+
+	// launch server
+	var wg sync.WaitGroup
+	listenSuccess := goben.Serve(context.Background(), server, &wg)
+	if !listenSuccess {
+		t.Error("server failed to listen")
+	}
+
+	// launch client
+	clientStats, err := goben.Open(context.Background(), client)
+	assert.NoError(t, err)
+	assert.Greater(t, clientStats.VerifiedBytes, int64(100))
+	assert.Equal(t, clientStats.ReadBytes, clientStats.VerifiedBytes)
+	assert.Equal(t, int64(0), clientStats.CorruptBytes)
+	assert.Equal(t, int64(0), clientStats.MissingBytes)
+}
+
+func TestEndToEndSendFile(t *testing.T) {
+
+	const size = 3000001
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.dat")
+	errWrite := os.WriteFile(src, make([]byte, size), 0o600)
+	assert.NoError(t, errWrite)
+
+	recvDir := filepath.Join(dir, "recv")
+	errMkdir := os.Mkdir(recvDir, 0o700)
+	assert.NoError(t, errMkdir)
+
+	// a client config sending a file
+	client := goben.NewDefaultConfig()
+	client.Hosts = goben.HostList{"127.0.0.1:18452"}
+	client.TLS = false
+	client.TCP = true
+	client.UDP = false
+	client.ReportInterval = "1s"
+	client.TotalDuration = "0"
+	client.SendFile = src
+	client.Opt.PassiveServer = true
+
+	// a server config writing received data to disk
+	server := goben.NewDefaultConfig()
+	server.Listeners = goben.HostList{"127.0.0.1:18452"}
+	server.TLS = false
+	server.TCP = true
+	server.UDP = false
+	server.WriteDir = recvDir
+
+	// launch server
+	var wg sync.WaitGroup
+	listenSuccess := goben.Serve(context.Background(), server, &wg)
+	if !listenSuccess {
+		t.Error("server failed to listen")
+	}
+
+	// launch client
+	clientStats, err := goben.Open(context.Background(), client)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(size), clientStats.WriteBytes)
+
+	// the server closes the file when the connection ends
+	var received int64
+	for range 20 {
+		files, _ := filepath.Glob(filepath.Join(recvDir, "goben-*.dat"))
+		if len(files) == 1 {
+			if info, errStat := os.Stat(files[0]); errStat == nil {
+				received = info.Size()
+			}
+		}
+		if received == size {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.Equal(t, int64(size), received)
+}
+
+func TestLibraryAPI(t *testing.T) {
+
+	// a server on a free port, without logging
+	server := goben.NewServer([]string{"127.0.0.1:0"})
+	errStart := server.Start(context.Background())
+	if !assert.NoError(t, errStart) {
+		return
+	}
+	defer server.Close()
+
+	addrs := server.Addrs()
+	if !assert.Len(t, addrs, 1) {
+		return
+	}
+
+	var averages atomic.Int32
+	client := goben.NewClient([]string{addrs[0].String()},
+		goben.WithConnections(2),
+		goben.WithDuration(time.Second),
+		goben.WithReportInterval(200*time.Millisecond),
+		goben.WithReportFunc(func(r goben.Report) {
+			if r.Kind == "average" {
+				averages.Add(1)
+			}
+		}))
+
+	result, err := client.Run(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Greater(t, result.ReadMbps, float64(0))
+	assert.Greater(t, result.WriteMbps, float64(0))
+	assert.NotEmpty(t, result.Reports)
+	assert.Equal(t, int32(4), averages.Load()) // reader and writer of each connection
+}
+
+func TestEndToEndHostStats(t *testing.T) {
+
+	// a client config with one live host and one without a server
+	client := goben.NewDefaultConfig()
+	client.Hosts = goben.HostList{"127.0.0.1:18453", "127.0.0.1:18454"}
+	client.TLS = false
+	client.TCP = true
+	client.UDP = false
+	client.ReportInterval = "200ms"
+	client.TotalDuration = "1s"
+	client.Connections = 2
+
+	// a server config
+	server := goben.NewDefaultConfig()
+	server.Listeners = goben.HostList{"127.0.0.1:18453"}
+	server.TLS = false
+	server.TCP = true
+	server.UDP = false
+
+	// launch server
+	var wg sync.WaitGroup
+	listenSuccess := goben.Serve(context.Background(), server, &wg)
+	if !listenSuccess {
+		t.Error("server failed to listen")
+	}
+
+	// launch client
+	clientStats, err := goben.Open(context.Background(), client)
+	assert.NoError(t, err)
+	if !assert.Len(t, clientStats.Hosts, 2) {
+		return
+	}
+
+	live := clientStats.Hosts[0]
+	assert.Equal(t, "127.0.0.1:18453", live.Host)
+	assert.Empty(t, live.Errors)
+	assert.Len(t, live.Connections, 2)
+	assert.Equal(t, clientStats.ReadBytes, live.ReadBytes)
+	assert.Equal(t, clientStats.WriteBytes, live.WriteBytes)
+	for _, cs := range live.Connections {
+		assert.Equal(t, "TCP", cs.Proto)
+		assert.Equal(t, "127.0.0.1:18453", cs.Remote)
+		assert.Empty(t, cs.Errors)
+		assert.Greater(t, cs.Read.Mbps, float64(0))
+		assert.Greater(t, cs.Write.Calls, int64(0))
+		assert.NotEmpty(t, cs.Read.Intervals.YValues)
+		assert.NotEmpty(t, cs.Write.Intervals.YValues)
+	}
+
+	dead := clientStats.Hosts[1]
+	assert.Empty(t, dead.Connections)
+	assert.Len(t, dead.Errors, 2)
+}
+
+func TestLibraryRepeat(t *testing.T) {
+
+	server := goben.NewServer([]string{"127.0.0.1:0"})
+	errStart := server.Start(context.Background())
+	if !assert.NoError(t, errStart) {
+		return
+	}
+	defer server.Close()
+
+	client := goben.NewClient([]string{server.Addrs()[0].String()},
+		goben.WithDuration(500*time.Millisecond),
+		goben.WithMaxSpeed(100),
+		goben.WithRepeat(3, 100*time.Millisecond))
+
+	result, err := client.Run(context.Background())
+	if !assert.NoError(t, err) || !assert.NotNil(t, result.Repeat) {
+		return
+	}
+	repeat := result.Repeat
+	assert.Len(t, repeat.Runs, 3)
+	assert.Len(t, repeat.WriteRate.Values, 3)
+	assert.Equal(t, "Mbps", repeat.Unit)
+	assert.InDelta(t, 100, repeat.WriteRate.Mean, 10)
+	assert.LessOrEqual(t, repeat.WriteRate.CILow, repeat.WriteRate.Mean)
+	assert.GreaterOrEqual(t, repeat.WriteRate.CIHigh, repeat.WriteRate.Mean)
+	assert.Equal(t, repeat.Runs[2].WriteMbps, result.WriteMbps)
+}
+
+func TestLibraryRepeatFilePayload(t *testing.T) {
+	payload := filepath.Join(t.TempDir(), "payload.txt")
+	if err := os.WriteFile(payload, []byte("goben file payload\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := goben.NewServer([]string{"127.0.0.1:0"})
+	errStart := server.Start(context.Background())
+	if !assert.NoError(t, errStart) {
+		return
+	}
+	defer server.Close()
+
+	client := goben.NewClient([]string{server.Addrs()[0].String()},
+		goben.WithDuration(300*time.Millisecond),
+		goben.WithMaxSpeed(100),
+		goben.WithRepeat(2, 0),
+		goben.WithConfig(func(c *goben.Config) { c.Opt.Payload = "file:" + payload }))
+
+	result, err := client.Run(context.Background())
+	if !assert.NoError(t, err) || !assert.NotNil(t, result.Repeat) {
+		return
+	}
+	assert.Len(t, result.Repeat.Runs, 2)
+}
+
+func TestCompareCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, mbps ...float64) string {
+		filename := filepath.Join(dir, name)
+		var b strings.Builder
+		b.WriteString("DIRECTION,TIME,RATE\n")
+		for i, v := range mbps {
+			fmt.Fprintf(&b, "input,2026-01-02 15:04:0%d +0000 UTC,%v\n", i, v)
+		}
+		if err := os.WriteFile(filename, []byte(b.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+	baseline := write("base.csv", 100, 100, 100)
+	same := write("same.csv", 99, 100, 101)
+	slower := write("slower.csv", 80, 80, 80)
+
+	assert.Equal(t, compareOk, compare([]string{baseline, same}))
+	assert.Equal(t, compareRegression, compare([]string{baseline, slower}))
+	assert.Equal(t, compareOk, compare([]string{"--tolerance", "25", baseline, slower}))
+	assert.Equal(t, compareError, compare([]string{baseline}))
+	assert.Equal(t, compareError, compare([]string{baseline, filepath.Join(dir, "missing.yaml")}))
+}
+
+func TestRenderCommand(t *testing.T) {
+	dir := t.TempDir()
+	export := filepath.Join(dir, "run.csv")
+	csv := "DIRECTION,TIME,RATE\ninput,2026-01-02 15:04:01 +0000 UTC,100\ninput,2026-01-02 15:04:02 +0000 UTC,120\n"
+	if err := os.WriteFile(export, []byte(csv), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"chart.png", "chart.svg", "report.html", "plot.txt"} {
+		output := filepath.Join(dir, name)
+		assert.Equal(t, renderOk, render([]string{"-o", output, export, export}), name)
+		info, err := os.Stat(output)
+		assert.NoError(t, err)
+		assert.NotZero(t, info.Size(), name)
+	}
+
+	assert.Equal(t, renderUsage, render(nil))
+	assert.Equal(t, renderUsage, render([]string{"--format", "png", export}))
+	assert.Equal(t, renderError, render([]string{filepath.Join(dir, "missing.yaml")}))
+	assert.Equal(t, renderError, render([]string{"--format", "gif", export}))
+	for _, bad := range []string{"0x0", "-800x300", "800", "axb", "800x50"} {
+		assert.Equal(t, renderUsage, render([]string{"--size", bad, export}), bad)
+	}
+
+	// a failed render leaves no partial file behind
+	failed := filepath.Join(dir, "failed.out")
+	assert.Equal(t, renderError, render([]string{"-o", failed, "--format", "gif", export}))
+	_, errStat := os.Stat(failed)
+	assert.ErrorIs(t, errStat, os.ErrNotExist)
+}
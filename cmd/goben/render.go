@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	"github.com/udhos/goben/goben"
+)
+
+// exit codes of the render command
+const (
+	renderOk    = 0
+	renderError = 1
+	renderUsage = 2
+)
+
+// render implements: goben render [flags] export...
+func render(args []string) int {
+	flagset := pflag.NewFlagSet("render", pflag.ContinueOnError)
+	flagset.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: goben render [flags] export.yaml|csv...\n")
+		fmt.Fprintf(os.Stderr, "renders exports as one chart, overlaying several runs on elapsed time\n")
+		flagset.PrintDefaults()
+	}
+	output := flagset.StringP("output", "o", "", "output file, its extension picks the format unless --format is set (default stdout)")
+	format := flagset.StringP("format", "f", "", "output format: "+strings.Join(goben.RenderFormats, ", ")+" (default from --output extension, else ascii)")
+	unit := flagset.String("unit", "Mbps", "rate unit: bps, Kbps, Mbps, Gbps, Tbps, bytes/s, KB/s, MB/s, GB/s or auto")
+	title := flagset.String("title", "", "chart title")
+	size := flagset.String("size", "1024x400", "size of png, svg and html charts in pixels, WIDTHxHEIGHT")
+	if err := flagset.Parse(args); err != nil {
+		return renderUsage
+	}
+	if flagset.NArg() == 0 {
+		flagset.Usage()
+		return renderUsage
+	}
+
+	opt := goben.RenderOptions{Format: *format, Unit: *unit, Title: *title}
+	var errSize error
+	opt.Width, opt.Height, errSize = goben.ParseChartSize(*size)
+	if errSize != nil {
+		fmt.Fprintf(os.Stderr, "render: %v\n", errSize)
+		return renderUsage
+	}
+	if opt.Format == "" {
+		opt.Format = goben.RenderFormat(*output)
+	}
+	if opt.Format == "" {
+		opt.Format = "ascii"
+	}
+	if opt.Format == "png" && *output == "" {
+		fmt.Fprintf(os.Stderr, "render: png output requires --output\n")
+		return renderUsage
+	}
+
+	var runs []goben.RenderRun
+	for _, filename := range flagset.Args() {
+		info, err := goben.ReadExport(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "render: %v\n", err)
+			return renderError
+		}
+		runs = append(runs, goben.RenderRun{Name: filepath.Base(filename), Info: info})
+	}
+
+	var err error
+	if *output == "" {
+		err = goben.RenderExports(os.Stdout, runs, opt)
+	} else {
+		err = renderFile(*output, runs, opt)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "render: %v\n", err)
+		return renderError
+	}
+	return renderOk
+}
+
+// renderFile renders runs into filename, removing it on failure
+// rather than leaving a truncated chart behind.
+func renderFile(filename string, runs []goben.RenderRun, opt goben.RenderOptions) error {
+	out, errCreate := os.Create(filename)
+	if errCreate != nil {
+		return errCreate
+	}
+	errRender := goben.RenderExports(out, runs, opt)
+	if errClose := out.Close(); errRender == nil {
+		errRender = errClose
+	}
+	if errRender != nil {
+		os.Remove(filename)
+	}
+	return errRender
+}
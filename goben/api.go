@@ -0,0 +1,244 @@
+package goben
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Result holds the outcome of a Client run.
+type Result struct {
+	ClientStats
+
+	Reports []Report // all reports of the run, in order of arrival
+
+	// statistics of repeated runs, nil unless WithRepeat;
+	// ClientStats then holds the last successful run, while Reports
+	// holds the reports of all runs, failed ones included, one run after
+	// the other: use Repeat.Runs for per-run results
+	Repeat *RepeatStats
+}
+
+// Option configures a Client or a Server.
+type Option func(*Config)
+
+// WithConnections sets the number of parallel connections to each host.
+func WithConnections(n int) Option {
+	return func(app *Config) { app.Connections = n }
+}
+
+// WithDuration sets the total test duration, 0 means bounded only by WithBytes.
+func WithDuration(d time.Duration) Option {
+	return func(app *Config) { app.TotalDuration = d.String() }
+}
+
+// WithReportInterval sets the periodic report interval.
+func WithReportInterval(d time.Duration) Option {
+	return func(app *Config) { app.ReportInterval = d.String() }
+}
+
+// WithBytes sets the bytes to transfer in each direction per connection.
+func WithBytes(n int64) Option {
+	return func(app *Config) { app.Opt.Bytes = n }
+}
+
+// WithMaxSpeed sets the bandwidth limit in Mbps.
+func WithMaxSpeed(mbps float64) Option {
+	return func(app *Config) { app.Opt.MaxSpeed = mbps }
+}
+
+// WithUnit sets the rate unit of reports, summary and exports, as in --unit.
+func WithUnit(unit string) Option {
+	return func(app *Config) { app.Unit = unit }
+}
+
+// WithRepeat runs the test n times, pausing between runs.
+func WithRepeat(n int, pause time.Duration) Option {
+	return func(app *Config) {
+		app.Repeat = n
+		app.RepeatPause = pause.String()
+	}
+}
+
+// WithThresholds sets pass/fail thresholds evaluated on the results,
+// see ClientStats.Thresholds: minimum rate in Mbps, maximum UDP loss
+// percentage and maximum TCP round-trip time. Zero rate or RTT and
+// negative loss disable a threshold. With WithRepeat, each run is
+// checked on its own, see the Runs of Result.Repeat.
+func WithThresholds(minMbps, maxLoss float64, maxRTT time.Duration) Option {
+	return func(app *Config) {
+		app.MinMbps = minMbps
+		app.MaxLoss = ""
+		if maxLoss >= 0 {
+			app.MaxLoss = strconv.FormatFloat(maxLoss, 'f', -1, 64)
+		}
+		app.MaxRTT = maxRTT.String()
+	}
+}
+
+// WithUDP switches to the UDP protocol.
+func WithUDP() Option {
+	return func(app *Config) { app.UDP = true }
+}
+
+// WithPassiveServer suppresses server traffic.
+func WithPassiveServer() Option {
+	return func(app *Config) { app.Opt.PassiveServer = true }
+}
+
+// WithPassiveClient suppresses client traffic.
+func WithPassiveClient() Option {
+	return func(app *Config) { app.PassiveClient = true }
+}
+
+// WithTLS enables TLS with the given PEM files.
+func WithTLS(cert, key, ca string) Option {
+	return func(app *Config) {
+		app.TLS = true
+		app.TLSCert = cert
+		app.TLSKey = key
+		app.TLSCA = ca
+	}
+}
+
+// WithExport sets the export modes, as in --export.
+func WithExport(items ...string) Option {
+	return func(app *Config) { app.Export = items }
+}
+
+// WithLogger sends the log of a run to l, see also NewLogger.
+// Final results are logged at LevelSummary.
+func WithLogger(l *slog.Logger) Option {
+	return func(app *Config) { app.logger = l }
+}
+
+// WithOutput sends the output of the table, json and live reporters
+// and of ASCII exports to w, discarded by default.
+func WithOutput(w io.Writer) Option {
+	return func(app *Config) { app.output = w }
+}
+
+// WithReporter sends the reports of a run to r.
+// By default, reports go to the logger in the classic log format.
+func WithReporter(r Reporter) Option {
+	return func(app *Config) { app.reporter = r }
+}
+
+// WithReportFunc calls f for every report of a run, besides the reporter.
+// f is called concurrently by all connections.
+func WithReportFunc(f func(Report)) Option {
+	return func(app *Config) { app.onReport = f }
+}
+
+// WithConfig gives direct access to the underlying Config,
+// for settings without a dedicated option.
+func WithConfig(f func(*Config)) Option {
+	return f
+}
+
+// newAPIConfig creates a config with the library defaults:
+// plain TCP, no exports, no logging and no output.
+func newAPIConfig(opts []Option) *Config {
+	app := NewDefaultConfig()
+	app.TLS = false
+	app.Export = []string{"none"}
+	app.logger = slog.New(slog.DiscardHandler)
+	app.output = io.Discard
+	for _, o := range opts {
+		o(app)
+	}
+	return app
+}
+
+// Client runs tests against goben servers.
+type Client struct {
+	app *Config
+}
+
+// NewClient creates a client for the given hosts.
+func NewClient(hosts []string, opts ...Option) *Client {
+	app := newAPIConfig(opts)
+	app.Hosts = hosts
+	return &Client{app: app}
+}
+
+// Run performs a test. It may be called again for another test.
+func (c *Client) Run(ctx context.Context) (*Result, error) {
+	app := *c.app // validation updates the config
+
+	var result Result
+	var mutex sync.Mutex
+	app.onReport = func(r Report) {
+		mutex.Lock()
+		result.Reports = append(result.Reports, r)
+		mutex.Unlock()
+		if c.app.onReport != nil {
+			c.app.onReport(r)
+		}
+	}
+
+	if app.Repeat > 1 {
+		repeat, err := OpenRepeat(ctx, &app)
+		if err != nil {
+			return nil, err
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		result.ClientStats = repeat.Runs[len(repeat.Runs)-1]
+		result.Repeat = &repeat
+		return &result, nil
+	}
+
+	stats, err := Open(ctx, &app)
+	if err != nil {
+		return nil, err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	result.ClientStats = stats
+	return &result, nil
+}
+
+// Server serves goben clients.
+type Server struct {
+	app    *Config
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// NewServer creates a server for the given listen addresses.
+// A port 0 picks a free port, see Addrs.
+func NewServer(listeners []string, opts ...Option) *Server {
+	app := newAPIConfig(opts)
+	app.Listeners = listeners
+	return &Server{app: app}
+}
+
+// Start starts listening, then serves until ctx is cancelled or Close is called.
+func (s *Server) Start(ctx context.Context) error {
+	ctx, s.cancel = context.WithCancel(ctx)
+	if !Serve(ctx, s.app, &s.wg) {
+		s.cancel()
+		return errors.New("server failed to listen")
+	}
+	return nil
+}
+
+// Addrs returns the addresses the server is listening on.
+func (s *Server) Addrs() []net.Addr {
+	return s.app.addrs
+}
+
+// Close stops the server and waits for its listeners to exit.
+func (s *Server) Close() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
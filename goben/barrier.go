@@ -0,0 +1,61 @@
+package goben
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// startBarrier releases all connections of a client run at the same time,
+// once every one of them is established and acknowledged.
+type startBarrier struct {
+	mutex   sync.Mutex
+	pending int
+	release chan struct{}
+	start   time.Time // common start time, valid after release
+}
+
+// newStartBarrier creates a barrier holding one pending slot for the dialer,
+// so it cannot be released before all connections have been spawned.
+// The dialer must call arrive when done spawning.
+func newStartBarrier() *startBarrier {
+	return &startBarrier{
+		pending: 1,
+		release: make(chan struct{}),
+	}
+}
+
+// add registers one more connection to wait for.
+func (b *startBarrier) add() {
+	b.mutex.Lock()
+	b.pending++
+	b.mutex.Unlock()
+}
+
+// arrive marks one connection as ready (or failed).
+// The last arrival releases the barrier.
+func (b *startBarrier) arrive() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.pending--
+	if b.pending == 0 {
+		b.start = time.Now()
+		close(b.release)
+	}
+}
+
+// wait blocks until the barrier is released and returns the common start time.
+// It returns false if ctx is cancelled while waiting.
+func (b *startBarrier) wait(ctx context.Context) (time.Time, bool) {
+	select {
+	case <-b.release:
+		return b.start, true
+	case <-ctx.Done():
+		return time.Time{}, false
+	}
+}
+
+// sleepUntil waits until t, returning false if ctx is cancelled while waiting.
+func sleepUntil(ctx context.Context, t time.Time) bool {
+	return rampWait(ctx, time.Until(t))
+}
@@ -0,0 +1,11 @@
+//go:build linux
+
+package goben
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func bindToDevice(fd uintptr, device string) error {
+	return unix.BindToDevice(int(fd), device)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package goben
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// SO_BINDTODEVICE is only supported on linux.
+func bindToDevice(_ uintptr, _ string) error {
+	return fmt.Errorf("bindToDevice: not supported on %s", runtime.GOOS)
+}
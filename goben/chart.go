@@ -1,57 +1,276 @@
 package goben
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/wcharczuk/go-chart"
 )
 
-func chartRender(filename string, input *ChartData, output *ChartData) error {
+// defaultChartSize is the default --chartSize, in pixels.
+const defaultChartSize = "1024x400"
 
-	log.Printf("chartRender: input data points:  %d/%d", len(input.XValues), len(input.YValues))
-	log.Printf("chartRender: output data points: %d/%d", len(output.XValues), len(output.YValues))
+// chartStyle controls the layout of png and svg charts.
+type chartStyle struct {
+	title         string
+	width, height int  // pixels, 0 means the go-chart default
+	secondaryAxis bool // output on its own right-hand axis, instead of sharing the rate axis
+}
 
-	out, errCreate := os.Create(filename)
-	if errCreate != nil {
-		return errCreate
+// chartStyle returns the chart layout of the config, with a title.
+func (app *Config) chartStyle(title string) chartStyle {
+	return chartStyle{
+		title:         title,
+		width:         app.chartWidth,
+		height:        app.chartHeight,
+		secondaryAxis: app.ChartSecondaryAxis,
+	}
+}
+
+// ParseChartSize parses WIDTHxHEIGHT in pixels, as in --chartSize,
+// and checks that the chart is at least 100x100 pixels.
+func ParseChartSize(size string) (int, int, error) {
+	w, h, found := strings.Cut(strings.ToLower(size), "x")
+	width, errWidth := strconv.Atoi(w)
+	height, errHeight := strconv.Atoi(h)
+	if !found || errWidth != nil || errHeight != nil || width < 100 || height < 100 {
+		return 0, 0, fmt.Errorf("bad chart size: %q (expected WIDTHxHEIGHT of at least 100x100 pixels, e.g. %s)", size, defaultChartSize)
+	}
+	return width, height, nil
+}
+
+func updateChartSize(app *Config) error {
+	if app.ChartSize == "" {
+		app.chartWidth, app.chartHeight = 0, 0
+		return nil
+	}
+	var err error
+	app.chartWidth, app.chartHeight, err = ParseChartSize(app.ChartSize)
+	return err
+}
+
+// renderSeries is one line of a chart.
+type renderSeries struct {
+	name      string
+	direction string    // input or output
+	x         []float64 // seconds since the first sample of the chart
+	y         []float64 // rate in the chart unit
+}
+
+// chartLine converts chart data into a line over the seconds elapsed since t0,
+// dividing rates by div to convert them to the chart unit.
+func chartLine(name, direction string, data ChartData, t0 time.Time, div float64) renderSeries {
+	s := renderSeries{name: name, direction: direction}
+	for i, t := range data.XValues {
+		s.x = append(s.x, t.Sub(t0).Seconds())
+		s.y = append(s.y, data.YValues[i]/div)
+	}
+	return s
+}
+
+// firstSample returns the time of the earliest sample in data.
+func firstSample(data ...ChartData) (time.Time, bool) {
+	var first time.Time
+	for _, d := range data {
+		for _, t := range d.XValues {
+			if first.IsZero() || t.Before(first) {
+				first = t
+			}
+		}
+	}
+	return first, !first.IsZero()
+}
+
+// renderChart draws series on elapsed seconds. Rates share one axis from zero,
+// so that lines differing 10x look it, unless style.secondaryAxis moves
+// the output lines to their own axis.
+func renderChart(w io.Writer, rp chart.RendererProvider, series []renderSeries, unit string, style chartStyle) error {
+	var peak, peakOutput float64
+	for _, s := range series {
+		for _, y := range s.y {
+			if style.secondaryAxis && s.direction == "output" {
+				peakOutput = max(peakOutput, y)
+			} else {
+				peak = max(peak, y)
+			}
+		}
 	}
-	defer out.Close()
 
 	graph := chart.Chart{
+		Title:      style.title,
+		TitleStyle: chart.Style{Show: style.title != ""},
+		Width:      style.width,
+		Height:     style.height,
 		XAxis: chart.XAxis{
-			Name: "Time",
-			Style: chart.Style{
-				Show: true, //enables / displays the x-axis
-			},
-			TickPosition:   chart.TickPositionBetweenTicks,
-			ValueFormatter: chart.TimeMinuteValueFormatter,
+			Name:      "Elapsed seconds",
+			NameStyle: chart.StyleShow(),
+			Style:     chart.StyleShow(),
 		},
 		YAxis: chart.YAxis{
-			Name: "Mbps",
-			Style: chart.Style{
-				Show: true, //enables / displays the y-axis
-			},
-		},
-		YAxisSecondary: chart.YAxis{
-			Style: chart.Style{
-				Show: true, //enables / displays the secondary y-axis
-			},
-		},
-		Series: []chart.Series{
-			chart.TimeSeries{
-				Name:    "Input",
-				XValues: input.XValues,
-				YValues: input.YValues,
-			},
-			chart.TimeSeries{
-				Name:    "Output",
-				YAxis:   chart.YAxisSecondary,
-				XValues: output.XValues,
-				YValues: output.YValues,
-			},
+			Name:      unit,
+			NameStyle: chart.StyleShow(),
+			Style:     chart.StyleShow(),
+			Range:     rateRange(peak),
 		},
 	}
+	if style.secondaryAxis {
+		graph.YAxis.Name = "input " + unit
+		graph.YAxisSecondary = chart.YAxis{
+			Name:      "output " + unit,
+			NameStyle: chart.StyleShow(),
+			Style:     chart.StyleShow(),
+			Range:     rateRange(peakOutput),
+		}
+	}
+	if r := timeRange(series); r != nil {
+		graph.XAxis.Range = r
+	}
+	if style.title != "" {
+		graph.Background = chart.Style{Padding: chart.Box{Top: 50, Left: 20, Right: 20, Bottom: 20}}
+	}
+
+	for _, s := range series {
+		cs := chart.ContinuousSeries{
+			Name:    s.name,
+			XValues: s.x,
+			YValues: s.y,
+		}
+		if style.secondaryAxis && s.direction == "output" {
+			cs.YAxis = chart.YAxisSecondary
+		}
+		graph.Series = append(graph.Series, cs)
+	}
+	graph.Elements = []chart.Renderable{chart.Legend(&graph)}
+	return graph.Render(rp, w)
+}
+
+// timeRange pads the time axis of series sampled at a single instant,
+// e.g. one report interval, which go-chart cannot scale, else returns nil
+// for the range of the samples.
+func timeRange(series []renderSeries) *chart.ContinuousRange {
+	var first, last float64
+	var found bool
+	for _, s := range series {
+		for _, x := range s.x {
+			if !found {
+				first, last, found = x, x, true
+			}
+			first, last = min(first, x), max(last, x)
+		}
+	}
+	if !found || first != last {
+		return nil
+	}
+	return &chart.ContinuousRange{Min: max(first-1, 0), Max: last + 1}
+}
+
+// rateRange starts a rate axis at zero, with headroom above the peak.
+func rateRange(peak float64) *chart.ContinuousRange {
+	if peak <= 0 {
+		peak = 1
+	}
+	return &chart.ContinuousRange{Min: 0, Max: peak * 1.1}
+}
+
+func rendererProvider(format string) chart.RendererProvider {
+	if format == "svg" {
+		return chart.SVG
+	}
+	return chart.PNG
+}
+
+// chartRender renders the chart of one connection in format png or svg.
+func chartRender(ctx context.Context, filename, format string, info *ExportInfo, style chartStyle) error {
+	input, output := &info.Input, &info.Output
+
+	debugf(ctx, "chartRender: input data points:  %d/%d", len(input.XValues), len(input.YValues))
+	debugf(ctx, "chartRender: output data points: %d/%d", len(output.XValues), len(output.YValues))
+
+	t0, found := firstSample(*input, *output)
+	if !found {
+		return fmt.Errorf("no data points")
+	}
+	var series []renderSeries
+	if len(input.YValues) > 0 {
+		series = append(series, chartLine("Input", "input", *input, t0, 1)) // already in info.Unit
+	}
+	if len(output.YValues) > 0 {
+		series = append(series, chartLine("Output", "output", *output, t0, 1))
+	}
+
+	out, errCreate := os.Create(filename)
+	if errCreate != nil {
+		return errCreate
+	}
+	defer out.Close()
+
+	return renderChart(out, rendererProvider(format), series, info.Unit, style)
+}
+
+// overlayFilename names the --chartOverlay chart after a png or svg export filename:
+// result-%d-%s.png is result-all-overlay.png, and chart.png is chart-overlay.png.
+func overlayFilename(filename string) string {
+	if strings.Contains(filename, "%") {
+		return strings.NewReplacer("%d", "all", "%s", "overlay").Replace(filename)
+	}
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "-overlay" + ext
+}
+
+// overlayRender renders the rates of every connection and their aggregate
+// on one chart, in format png or svg. Connections share the time axis,
+// so ramped up connections start later.
+func overlayRender(ctx context.Context, filename, format string, app *Config, s ClientStats) error {
+	var input, output, all []ChartData
+	var names []string
+	var peak float64
+	for _, h := range s.Hosts {
+		for _, c := range h.Connections {
+			input = append(input, c.Read.Intervals)
+			output = append(output, c.Write.Intervals)
+			all = append(all, c.Read.Intervals, c.Write.Intervals)
+			names = append(names, fmt.Sprintf("%s #%d", c.Host, c.Index))
+		}
+	}
+	aggInput, aggOutput := sumIntervals(input, app.Opt.ReportInterval), sumIntervals(output, app.Opt.ReportInterval)
+	for _, d := range append(all, aggInput, aggOutput) {
+		for _, y := range d.YValues {
+			peak = max(peak, y)
+		}
+	}
+	t0, found := firstSample(all...)
+	if !found {
+		return fmt.Errorf("no data points")
+	}
+	u := app.unit.forValue(peak)
+
+	var series []renderSeries
+	add := func(name, direction string, data ChartData) {
+		if len(data.YValues) > 0 {
+			series = append(series, chartLine(name+" "+direction, direction, data, t0, u.mbps))
+		}
+	}
+	for i, name := range names {
+		add(name, "input", input[i])
+		add(name, "output", output[i])
+	}
+	add("aggregate", "input", aggInput)
+	add("aggregate", "output", aggOutput)
+
+	debugf(ctx, "overlayRender: %d connections, %d lines", len(names), len(series))
+
+	out, errCreate := os.Create(filename)
+	if errCreate != nil {
+		return errCreate
+	}
+	defer out.Close()
 
-	return graph.Render(chart.PNG, out)
+	title := fmt.Sprintf("%d connections to %s", len(names), strings.Join(app.Hosts, ", "))
+	return renderChart(out, rendererProvider(format), series, u.name, app.chartStyle(title))
 }
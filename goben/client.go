@@ -8,12 +8,14 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/gob"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net"
 	"os"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -22,10 +24,34 @@ import (
 // ClientStats records stats for client side.
 type ClientStats struct {
 	TotalDuration time.Duration
+	Elapsed       time.Duration // longest connection transfer time
 	ReadMbps      float64
 	WriteMbps     float64
+	ReadCps       int64 // Call/s
+	WriteCps      int64
 	ReadBytes     int64
 	WriteBytes    int64
+	Retransmits   uint64 // total TCP retransmitted segments (linux only)
+
+	RetransmitsSampled bool // Retransmits comes from TCP_INFO, false for UDP or without TCP_INFO
+
+	// data integrity of received traffic (--verify)
+	VerifiedBytes    int64
+	CorruptBytes     int64
+	CorruptDatagrams int64
+	MissingBytes     int64 // never arrived: stream cut short of --bytes/--blocks or truncated datagrams
+
+	// UDP datagrams from the server, counted from their sequence numbers (--maxLoss or --verify)
+	ReceivedDatagrams int64
+	LostDatagrams     int64
+
+	// disk-bound throughput of --sendFile: bytes over time spent reading the file,
+	// summed over connections
+	DiskReadMbps float64
+
+	Hosts []HostStats // per-host and per-connection results
+
+	Thresholds []ThresholdCheck // --minMbps, --maxLoss and --maxRTT results
 }
 
 // Open opens a client with a config and performs a test.
@@ -36,6 +62,8 @@ func Open(ctx context.Context, app *Config) (ClientStats, error) {
 		return ClientStats{}, err
 	}
 
+	ctx = withHooks(ctx, app.hooks())
+
 	// then open the connection
 	var proto string
 	if app.UDP {
@@ -44,55 +72,78 @@ func Open(ctx context.Context, app *Config) (ClientStats, error) {
 		proto = "tcp"
 	}
 
-	var wg sync.WaitGroup
+	if app.Opt.Verify && app.Opt.Seed == 0 {
+		app.Opt.Seed = randSeed()
+	}
+	if app.Opt.Verify {
+		infof(ctx, "open: verifying data integrity with seed=%d", app.Opt.Seed)
+	}
+
+	run := newClientRun(app)
 
-	var aggReader aggregate
-	var aggWriter aggregate
+	if app.Opt.SyncStart {
+		run.barrier = newStartBarrier()
+	}
 
 	dialer := net.Dialer{}
 
-	if app.LocalAddr != "" {
-		if app.UDP {
-			addr, err := net.ResolveUDPAddr(proto, app.LocalAddr)
-			if err != nil {
-				log.Printf("open: resolve %s localAddr=%s: %v", proto, app.LocalAddr, err)
-			}
-			dialer.LocalAddr = addr
-		} else {
-			addr, err := net.ResolveTCPAddr(proto, app.LocalAddr)
-			if err != nil {
-				log.Printf("open: resolve %s localAddr=%s: %v", proto, app.LocalAddr, err)
-			}
-			dialer.LocalAddr = addr
-		}
-		log.Printf("open: localAddr: %s", dialer.LocalAddr)
+	if app.BindDevice != "" {
+		infof(ctx, "open: binding to device: %s", app.BindDevice)
+	}
+
+	if app.Opt.TOS != 0 {
+		infof(ctx, "open: marking traffic with TOS=0x%02x", app.Opt.TOS)
+	}
+
+	dialer.Control = socketControl(app.Opt.TOS, app.BindDevice)
+
+	if app.rampInterval > 0 {
+		infof(ctx, "open: ramping up: one connection every %v", app.rampInterval)
 	}
 
+	var started int
 	successfulConnections := 0
-	for _, h := range app.Hosts {
+HOSTS:
+	for h, t := range app.targets {
 
-		hh := appendPortIfMissing(h, app.DefaultPort)
+		hh := t.host
 
-		for i := 0; i < app.Connections; i++ {
+		dialer.LocalAddr = t.localAddr
+		if t.localAddr != nil {
+			debugf(ctx, "open: %s localAddr: %s", hh, t.localAddr)
+		}
+
+		for i := 0; i < t.connections; i++ {
+
+			// with synchronized start, ramp up delays the start of traffic instead of dialing
+			if !app.Opt.SyncStart && started > 0 && !rampWait(ctx, app.rampInterval) {
+				warnf(ctx, "open: ramp up interrupted: %v", ctx.Err())
+				break HOSTS
+			}
+			seq := started
+			started++
 
-			log.Printf("open: opening TLS=%v %s %d/%d: %s", app.TLS, proto, i, app.Connections, hh)
+			infof(ctx, "open: opening TLS=%v %s %d/%d: %s", app.TLS, proto, i, t.connections, hh)
 
 			if !app.UDP && app.TLS {
 				// try TLS first
-				log.Printf("open: trying TLS")
-				conn, errDialTLS := tlsDial(dialer, proto, hh, app)
+				debugf(ctx, "open: trying TLS")
+				conn, errDialTLS := tlsDial(ctx, dialer, proto, hh, app)
 				if errDialTLS == nil {
-					spawnClient(ctx, app, &wg, conn, i, app.Connections, true, &aggReader, &aggWriter)
+					spawnClient(ctx, run, conn, h, i, seq, true)
 					successfulConnections++
 					continue
 				}
-				log.Printf("open: trying TLS: failure: %s: %s: %v", proto, hh, errDialTLS)
+				warnf(ctx, "open: trying TLS: failure: %s: %s: %v", proto, hh, errDialTLS)
+				if !app.TCP {
+					run.hostError(h, errDialTLS)
+				}
 			}
 
 			if !app.UDP && app.TCP {
-				log.Printf("open: trying non-TLS TCP")
+				debugf(ctx, "open: trying non-TLS TCP")
 			} else if !app.UDP {
-				log.Printf("open: all enabled options failed to connect, aborting")
+				warnf(ctx, "open: all enabled options failed to connect, aborting")
 				continue
 			}
 
@@ -100,46 +151,178 @@ func Open(ctx context.Context, app *Config) (ClientStats, error) {
 			if app.UDP || app.TCP {
 				conn, errDial := dialer.Dial(proto, hh)
 				if errDial != nil {
-					log.Printf("open: dial %s: %s: %v", proto, hh, errDial)
+					warnf(ctx, "open: dial %s: %s: %v", proto, hh, errDial)
+					run.hostError(h, errDial)
 					continue
 				}
-				spawnClient(ctx, app, &wg, conn, i, app.Connections, false, &aggReader, &aggWriter)
+				spawnClient(ctx, run, conn, h, i, seq, false)
 				successfulConnections++
 			}
 		}
 	}
 
+	if run.barrier != nil {
+		run.barrier.arrive() // all connections spawned
+	}
+
 	if successfulConnections == 0 {
-		log.Printf("open: no successful connections")
+		errorf(ctx, "open: no successful connections")
 		return ClientStats{}, fmt.Errorf("open: no successful connections")
 	}
 
-	wg.Wait()
+	run.wg.Wait()
+
+	aggReader := &run.aggReader
+	aggWriter := &run.aggWriter
 
-	log.Printf("aggregate reading: %f Mbps %d recv/s", aggReader.Mbps, aggReader.Cps)
-	log.Printf("aggregate writing: %f Mbps %d send/s", aggWriter.Mbps, aggWriter.Cps)
+	if app.Opt.hasLimit() {
+		summaryf(ctx, "transfer time: %v", run.elapsed)
+	}
 
-	return ClientStats{
+	stats := ClientStats{
 		TotalDuration: app.Opt.TotalDuration,
+		Elapsed:       run.elapsed,
 		ReadMbps:      aggReader.Mbps,
 		WriteMbps:     aggWriter.Mbps,
+		ReadCps:       aggReader.Cps,
+		WriteCps:      aggWriter.Cps,
 		ReadBytes:     aggReader.Bytes,
 		WriteBytes:    aggWriter.Bytes,
-	}, nil
+		Retransmits:   aggWriter.Retransmits,
+
+		RetransmitsSampled: aggWriter.retransmitsSampled,
+
+		VerifiedBytes:    aggReader.VerifiedBytes,
+		CorruptBytes:     aggReader.CorruptBytes,
+		CorruptDatagrams: aggReader.CorruptDatagrams,
+		MissingBytes:     aggReader.MissingBytes,
+
+		ReceivedDatagrams: aggReader.Datagrams,
+		LostDatagrams:     aggReader.LostDatagrams,
+
+		DiskReadMbps: aggWriter.DiskMbps,
+
+		Hosts: run.hostStats(),
+	}
+
+	if app.hasThresholds() {
+		stats.Thresholds = app.checkThresholds(stats)
+	}
+
+	hooksFrom(ctx).summary(stats)
+
+	if app.hasThresholds() {
+		logThresholds(ctx, stats.Thresholds)
+	}
+
+	for _, t := range app.exports {
+		switch {
+		case t.Mode == "html":
+			filename := app.exportFilename(t.Filename)
+			infof(ctx, "exporting HTML report to: %s", filename)
+			if errExport := exportReport(ctx, filename, app, stats); errExport != nil {
+				warnf(ctx, "open: export HTML: %s: %v", filename, errExport)
+			}
+		case (t.Mode == "png" || t.Mode == "svg") && app.ChartOverlay && t.Filename != "":
+			filename := app.exportFilename(overlayFilename(t.Filename))
+			infof(ctx, "rendering overlay chart to: %s", filename)
+			if errRender := overlayRender(ctx, filename, t.Mode, app, stats); errRender != nil {
+				warnf(ctx, "open: render %s: %s: %v", strings.ToUpper(t.Mode), filename, errRender)
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// rampWait waits for the ramp up interval between connections.
+// It returns false if ctx is cancelled while waiting.
+func rampWait(ctx context.Context, interval time.Duration) bool {
+	if interval <= 0 {
+		return true
+	}
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
 
-func spawnClient(ctx context.Context, app *Config, wg *sync.WaitGroup, conn net.Conn, c, connections int, isTLS bool, aggReader, aggWriter *aggregate) {
-	wg.Add(1)
-	go handleConnectionClient(ctx, app, wg, conn, c, connections, isTLS, aggReader, aggWriter)
+// clientRun holds state shared by all connections of a client test run.
+type clientRun struct {
+	app       *Config
+	wg        sync.WaitGroup
+	aggReader aggregate
+	aggWriter aggregate
+	barrier   *startBarrier // nil unless synchronized start
+	hosts     []hostRun     // indexed like app.targets
+
+	mutex   sync.Mutex
+	elapsed time.Duration // longest connection transfer time
 }
 
-func tlsDial(dialer net.Dialer, proto, h string, app *Config) (net.Conn, error) {
+// newClientRun prepares a run, naming every host up front,
+// so that hosts skipped by an interrupted ramp up stay labeled.
+func newClientRun(app *Config) *clientRun {
+	run := &clientRun{app: app, hosts: make([]hostRun, len(app.targets))}
+	for h, t := range app.targets {
+		run.hosts[h].host = t.host
+	}
+	return run
+}
+
+func spawnClient(ctx context.Context, run *clientRun, conn net.Conn, h, c, seq int, isTLS bool) {
+	run.wg.Add(1)
+	if run.barrier != nil {
+		run.barrier.add()
+	}
+	cs := run.newConn(h, c, conn, isTLS)
+	go handleConnectionClient(ctx, run, conn, h, c, seq, isTLS, cs)
+}
+
+// connOptions returns the options for the seq-th connection of the run,
+// to host h, and its start offset within the common schedule.
+func (run *clientRun) connOptions(h, seq int) (Options, time.Duration) {
+	app := run.app
+	opt := app.Opt
+	opt.Payload = app.payload
+	opt.MaxSpeed = app.targets[h].maxSpeed
+
+	// ramped up connections run for the remainder of the common schedule
+	var offset time.Duration
+	if opt.SyncStart {
+		offset = time.Duration(seq) * app.rampInterval
+		if opt.TotalDuration > 0 {
+			opt.TotalDuration -= offset
+		}
+	}
+
+	if app.LimitTotal {
+		total := app.totalConnections()
+		opt.Bytes = splitLimit(opt.Bytes, seq, total)
+		opt.Blocks = splitLimit(opt.Blocks, seq, total)
+	}
+
+	return opt, offset
+}
+
+// addElapsed records the transfer time of one connection.
+func (run *clientRun) addElapsed(elapsed time.Duration) {
+	run.mutex.Lock()
+	run.elapsed = max(run.elapsed, elapsed)
+	run.mutex.Unlock()
+}
+
+func tlsDial(ctx context.Context, dialer net.Dialer, proto, h string, app *Config) (net.Conn, error) {
 
 	// load client cert, if this is not provided, it will not be sent along with the connection
 	clientCerts := []tls.Certificate{}
 	cert, err := tls.LoadX509KeyPair(app.TLSCert, app.TLSKey)
 	if err != nil {
-		log.Printf("tlsDial: failure loading TLS key pair: %v, will connect without explicitly specified key/cert", err)
+		warnf(ctx, "tlsDial: failure loading TLS key pair: %v, will connect without explicitly specified key/cert", err)
 	} else {
 		clientCerts = append(clientCerts, cert)
 	}
@@ -147,7 +330,7 @@ func tlsDial(dialer net.Dialer, proto, h string, app *Config) (net.Conn, error)
 	// by default use the system cert pool
 	caCertPool, err := x509.SystemCertPool()
 	if err != nil {
-		log.Printf("tlsDial: failure loading system cert pool: %v", err)
+		warnf(ctx, "tlsDial: failure loading system cert pool: %v", err)
 		return nil, err
 	}
 
@@ -155,7 +338,7 @@ func tlsDial(dialer net.Dialer, proto, h string, app *Config) (net.Conn, error)
 	if app.TLSCA != "" {
 		caCert, err := os.ReadFile(app.TLSCA)
 		if err != nil {
-			log.Printf("tlsDial: failure reading CA cert %s: %v", app.TLSCA, err)
+			warnf(ctx, "tlsDial: failure reading CA cert %s: %v", app.TLSCA, err)
 			return nil, err
 		}
 
@@ -174,108 +357,210 @@ func tlsDial(dialer net.Dialer, proto, h string, app *Config) (net.Conn, error)
 	// and dial
 	conn, err := tls.DialWithDialer(&dialer, proto, h, conf)
 	if err != nil {
-		log.Printf("tlsDial: %s %s: %v", proto, h, err)
+		warnf(ctx, "tlsDial: %s %s: %v", proto, h, err)
 		return nil, err
 	}
 
-	log.Println("client: connected to: ", conn.RemoteAddr())
+	debugf(ctx, "client: connected to: %v", conn.RemoteAddr())
 	state := conn.ConnectionState()
-	log.Println("Client: Server certificates:")
+	debugf(ctx, "Client: Server certificates:")
 	for _, v := range state.PeerCertificates {
-		log.Print("- Subject: ", v.Subject)
-		log.Print("  Issuer: ", v.Issuer)
-		log.Print("  Expiration: ", v.NotAfter)
+		debugf(ctx, "- Subject: %v", v.Subject)
+		debugf(ctx, "  Issuer: %v", v.Issuer)
+		debugf(ctx, "  Expiration: %v", v.NotAfter)
 	}
-	log.Println("client: handshake complete: ", state.HandshakeComplete)
+	debugf(ctx, "client: handshake complete: %v", state.HandshakeComplete)
 
 	return conn, err
 }
 
 // ExportInfo records data for export
 type ExportInfo struct {
+	Unit   string // rate unit of the values, as in --unit
 	Input  ChartData
 	Output ChartData
 }
 
-func sendOptions(app *Config, conn io.Writer) error {
-	opt := app.Opt
-	if app.UDP {
+// newExportInfo converts chart data from Mbps to the export unit,
+// auto picks one unit for both directions.
+func newExportInfo(input, output ChartData, unit rateUnit) ExportInfo {
+	var peak float64
+	for _, y := range slices.Concat(input.YValues, output.YValues) {
+		peak = max(peak, y)
+	}
+	unit = unit.forValue(peak)
+	return ExportInfo{
+		Unit:   unit.name,
+		Input:  scaleChart(input, unit),
+		Output: scaleChart(output, unit),
+	}
+}
+
+func sendOptions(ctx context.Context, udp bool, opt Options, conn io.Writer) error {
+	if udp {
 		var optBuf bytes.Buffer
 		enc := gob.NewEncoder(&optBuf)
 		if errOpt := enc.Encode(&opt); errOpt != nil {
-			log.Printf("handleConnectionClient: UDP options failure: %v", errOpt)
+			warnf(ctx, "handleConnectionClient: UDP options failure: %v", errOpt)
 			return errOpt
 		}
 		_, optWriteErr := conn.Write(optBuf.Bytes())
 		if optWriteErr != nil {
-			log.Printf("handleConnectionClient: UDP options write: %v", optWriteErr)
+			warnf(ctx, "handleConnectionClient: UDP options write: %v", optWriteErr)
 			return optWriteErr
 		}
 	} else {
 		enc := gob.NewEncoder(conn)
 		if errOpt := enc.Encode(&opt); errOpt != nil {
-			log.Printf("handleConnectionClient: TCP options failure: %v", errOpt)
+			warnf(ctx, "handleConnectionClient: TCP options failure: %v", errOpt)
 			return errOpt
 		}
 	}
 	return nil
 }
 
-func handleConnectionClient(ctx context.Context, app *Config, wg *sync.WaitGroup, conn net.Conn, c, connections int, isTLS bool, aggReader, aggWriter *aggregate) {
-	defer wg.Done()
+func handleConnectionClient(ctx context.Context, run *clientRun, conn net.Conn, h, c, seq int, isTLS bool, cs *ConnStats) {
+	defer run.wg.Done()
 
-	log.Printf("handleConnectionClient: starting %s %d/%d %v", protoLabel(isTLS), c, connections, conn.RemoteAddr())
+	app := run.app
+	connections := app.targets[h].connections
+	aggReader := &run.aggReader
+	aggWriter := &run.aggWriter
+
+	// a connection failing before the barrier must not hold the others
+	arrive := func() {}
+	if run.barrier != nil {
+		arrive = sync.OnceFunc(run.barrier.arrive)
+	}
+	defer arrive()
+
+	debugf(ctx, "handleConnectionClient: starting %s %d/%d %v", protoLabel(isTLS), c, connections, conn.RemoteAddr())
+
+	opt, offset := run.connOptions(h, seq)
+
+	var deadline time.Time
+
+	// UDP has no handshake: wait for the barrier before sending options, which start the server
+	if app.UDP && run.barrier != nil {
+		arrive()
+		var ok bool
+		if deadline, ok = waitStart(ctx, run, c, offset); !ok {
+			return
+		}
+	}
+
+	// the server runs its own timer from the options, or from the start message
+	peerStart := time.Now()
 
 	// send options
-	if errOpt := sendOptions(app, conn); errOpt != nil {
+	if errOpt := sendOptions(ctx, app.UDP, opt, conn); errOpt != nil {
+		cs.Errors = append(cs.Errors, errOpt.Error())
 		return
 	}
-	opt := app.Opt
-	log.Printf("handleConnectionClient: options sent: %v", opt)
+	debugf(ctx, "handleConnectionClient: options sent: %v", opt)
+
+	// UDP servers ack only sequence numbers, which --maxLoss cannot do without
+	if app.UDP && opt.SeqNumbers {
+		if errSeq := recvSeqAck(ctx, conn); errSeq != nil {
+			warnf(ctx, "handleConnectionClient: %d/%d %v", c, connections, errSeq)
+			cs.Errors = append(cs.Errors, errSeq.Error())
+			return
+		}
+	}
 
 	// receive ack
 	if !app.UDP {
 		var a ack
-		if errAck := ackRecv(app.UDP, conn, &a); errAck != nil {
-			log.Printf("handleConnectionClient: receiving ack: %v", errAck)
+		if errAck := ackRecv(ctx, app.UDP, conn, &a); errAck != nil {
+			warnf(ctx, "handleConnectionClient: receiving ack: %v", errAck)
+			cs.Errors = append(cs.Errors, errAck.Error())
 			return
 		}
-		log.Printf("handleConnectionClient: %s ack received", protoLabel(isTLS))
+		debugf(ctx, "handleConnectionClient: %s ack received", protoLabel(isTLS))
+
+		if run.barrier != nil {
+			arrive()
+			var ok bool
+			if deadline, ok = waitStart(ctx, run, c, offset); !ok {
+				return
+			}
+			if a.Table[ackSyncStart] == "true" {
+				peerStart = time.Now()
+				if errStart := startSend(ctx, conn); errStart != nil {
+					warnf(ctx, "handleConnectionClient: sending start: %v", errStart)
+					cs.Errors = append(cs.Errors, errStart.Error())
+					return
+				}
+			} else {
+				infof(ctx, "handleConnectionClient: %d/%d server does not wait for the start message, its timer started at the ack", c, connections)
+			}
+		}
+	}
+
+	if run.barrier == nil && opt.TotalDuration > 0 {
+		deadline = time.Now().Add(opt.TotalDuration)
 	}
 
 	doneReader := make(chan struct{})
 	doneWriter := make(chan struct{})
 
-	info := ExportInfo{
-		Input:  ChartData{},
-		Output: ChartData{},
-	}
+	var input, output ChartData
+	var readResult, writeResult loopResult
 
-	var input *ChartData
-	var output *ChartData
+	bufSizeIn, bufSizeOut := getBufSize(opt, app.UDP)
 
-	if len(app.exports) > 0 {
-		input = &info.Input
-		output = &info.Output
-	}
+	tcpInfo := tcpInfoSampler(conn)
 
-	bufSizeIn, bufSizeOut := getBufSize(opt, app.UDP)
+	begin := time.Now()
 
-	go clientReader(ctx, conn, c, connections, doneReader, bufSizeIn, opt, input, aggReader)
+	go clientReader(ctx, conn, c, connections, doneReader, bufSizeIn, opt, &input, &readResult, aggReader, tcpInfo, app.UDP)
 	if !app.PassiveClient {
-		go clientWriter(ctx, conn, c, connections, doneWriter, bufSizeOut, opt, output, aggWriter)
+		go clientWriter(ctx, conn, c, connections, doneWriter, bufSizeOut, opt, &output, &writeResult, aggWriter, tcpInfo, app.UDP, app.SendFile)
 	}
 
-	tickerPeriod := time.NewTimer(app.Opt.TotalDuration)
+	// with a byte/block limit, the test ends when the transfer completes;
+	// a UDP receive-only client cannot tell, so it waits for the deadline
+	var finished chan struct{}
+	if opt.hasLimit() && (!app.PassiveClient || (!app.UDP && !opt.PassiveServer)) {
+		finished = make(chan struct{})
+		go func() {
+			if !app.PassiveClient {
+				<-doneWriter
+			}
+			// UDP datagrams may be lost, so the reader limit may never be reached
+			if !app.UDP && !opt.PassiveServer {
+				<-doneReader
+			}
+			close(finished)
+		}()
+	}
+
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		tickerPeriod := time.NewTimer(time.Until(deadline))
+		defer tickerPeriod.Stop()
+		timeout = tickerPeriod.C
+	}
 
 	select {
-	case <-tickerPeriod.C:
-		log.Printf("handleConnectionClient: %v timer", app.Opt.TotalDuration)
+	case <-timeout:
+		infof(ctx, "handleConnectionClient: %v timer", opt.TotalDuration)
+	case <-finished:
+		infof(ctx, "handleConnectionClient: %d/%d transfer complete: %d bytes per direction in %v", c, connections, opt.byteLimit(app.UDP), time.Since(begin))
 	case <-ctx.Done():
-		log.Printf("handleConnectionClient: received shutdown signal")
+		infof(ctx, "handleConnectionClient: received shutdown signal")
 	}
 
-	tickerPeriod.Stop()
+	cs.Elapsed = time.Since(begin)
+	run.addElapsed(cs.Elapsed)
+
+	if tcpInfo != nil {
+		if ti, ok := tcpInfo(); ok {
+			infof(ctx, "handleConnectionClient: %d/%d %v retransmits: %d", c, connections, conn.RemoteAddr(), ti.Retransmits)
+			aggWriter.addRetransmits(ti.Retransmits)
+			cs.Retransmits = uint64(ti.Retransmits)
+		}
+	}
 
 	remoteAddr := formatAddress(conn)
 	conn.Close()
@@ -285,6 +570,18 @@ func handleConnectionClient(ctx context.Context, app *Config, wg *sync.WaitGroup
 		<-doneWriter
 	}
 
+	cs.Read = readResult.dirStats(input)
+	cs.Write = writeResult.dirStats(output)
+	for _, r := range []loopResult{readResult, writeResult} {
+		// past its deadline, the server closing the connection is not an error
+		peerEnded := opt.TotalDuration > 0 && r.end.After(peerStart.Add(opt.TotalDuration))
+		if r.err != nil && !peerEnded {
+			cs.Errors = append(cs.Errors, r.err.Error())
+		}
+	}
+
+	info := newExportInfo(input, output, app.unit)
+
 	for _, t := range app.exports {
 		var filename string
 		if t.Filename != "" {
@@ -293,41 +590,61 @@ func handleConnectionClient(ctx context.Context, app *Config, wg *sync.WaitGroup
 			} else {
 				filename = t.Filename
 			}
+			filename = app.exportFilename(filename)
 		}
 		switch t.Mode {
 		case "ascii":
 			if filename != "" {
-				log.Printf("exporting ASCII test results to: %s", filename)
+				infof(ctx, "exporting ASCII test results to: %s", filename)
 			}
-			plotasciiToFile(filename, &info, remoteAddr, c)
+			plotasciiToFile(ctx, app.getOutput(), filename, &info, remoteAddr, c)
 		case "csv":
 			if filename == "" {
 				continue
 			}
-			log.Printf("exporting CSV test results to: %s", filename)
+			infof(ctx, "exporting CSV test results to: %s", filename)
 			if errExport := exportCsv(filename, &info); errExport != nil {
-				log.Printf("handleConnectionClient: export CSV: %s: %v", filename, errExport)
+				warnf(ctx, "handleConnectionClient: export CSV: %s: %v", filename, errExport)
 			}
 		case "yaml":
 			if filename == "" {
 				continue
 			}
-			log.Printf("exporting YAML test results to: %s", filename)
+			infof(ctx, "exporting YAML test results to: %s", filename)
 			if errExport := export(filename, &info); errExport != nil {
-				log.Printf("handleConnectionClient: export YAML: %s: %v", filename, errExport)
+				warnf(ctx, "handleConnectionClient: export YAML: %s: %v", filename, errExport)
 			}
-		case "png":
+		case "png", "svg":
 			if filename == "" {
 				continue
 			}
-			log.Printf("rendering chart to: %s", filename)
-			if errRender := chartRender(filename, &info.Input, &info.Output); errRender != nil {
-				log.Printf("handleConnectionClient: render PNG: %s: %v", filename, errRender)
+			infof(ctx, "rendering chart to: %s", filename)
+			title := fmt.Sprintf("%s connection %d: %s %s", cs.Host, c, cs.Proto, remoteAddr)
+			if errRender := chartRender(ctx, filename, t.Mode, &info, app.chartStyle(title)); errRender != nil {
+				warnf(ctx, "handleConnectionClient: render %s: %s: %v", strings.ToUpper(t.Mode), filename, errRender)
 			}
 		}
 	}
 
-	log.Printf("handleConnectionClient: closing: %d/%d %v", c, connections, remoteAddr)
+	infof(ctx, "handleConnectionClient: closing: %d/%d %v", c, connections, remoteAddr)
+}
+
+// waitStart waits at the start barrier, then for this connection's ramp up offset.
+// It returns the common deadline for the run.
+func waitStart(ctx context.Context, run *clientRun, c int, offset time.Duration) (time.Time, bool) {
+	start, ok := run.barrier.wait(ctx)
+	if !ok {
+		warnf(ctx, "handleConnectionClient: %d: start barrier interrupted: %v", c, ctx.Err())
+		return time.Time{}, false
+	}
+	if !sleepUntil(ctx, start.Add(offset)) {
+		warnf(ctx, "handleConnectionClient: %d: ramp up interrupted: %v", c, ctx.Err())
+		return time.Time{}, false
+	}
+	if run.app.Opt.TotalDuration <= 0 {
+		return time.Time{}, true // bounded by bytes/blocks only
+	}
+	return start.Add(run.app.Opt.TotalDuration), true
 }
 
 func getBufSize(opt Options, isUDP bool) (bufSizeIn int, bufSizeOut int) {
@@ -341,39 +658,107 @@ func getBufSize(opt Options, isUDP bool) (bufSizeIn int, bufSizeOut int) {
 	return
 }
 
-func clientReader(ctx context.Context, conn net.Conn, c, connections int, done chan struct{}, bufSize int, opt Options, stat *ChartData, agg *aggregate) {
-	log.Printf("clientReader: starting: %d/%d %v", c, connections, conn.RemoteAddr())
+// recvSeqAck waits for the UDP server to confirm it stamps its datagrams with sequence numbers.
+func recvSeqAck(ctx context.Context, conn net.Conn) error {
+	if errDeadline := conn.SetReadDeadline(time.Now().Add(udpAckTimeout)); errDeadline != nil {
+		return errDeadline
+	}
+	var a ack
+	if errAck := ackRecv(ctx, true, conn, &a); errAck != nil {
+		return fmt.Errorf("server did not confirm UDP sequence numbers, required by --maxLoss (server older than goben 1.2.0, or lost ack): %w", errAck)
+	}
+	if a.Table[ackSeqNumbers] != "true" {
+		return errors.New("server does not support UDP sequence numbers, required by --maxLoss")
+	}
+	return conn.SetReadDeadline(time.Time{})
+}
+
+func clientReader(ctx context.Context, conn net.Conn, c, connections int, done chan struct{}, bufSize int, opt Options, stat *ChartData, result *loopResult, agg *aggregate, tcpInfo tcpInfoFunc, udp bool) {
+	debugf(ctx, "clientReader: starting: %d/%d %v", c, connections, conn.RemoteAddr())
 
 	connIndex := fmt.Sprintf("%d/%d", c, connections)
 
 	buf := make([]byte, bufSize)
 
-	workLoop(ctx, connIndex, "clientReader", "rcv/s", conn.Read, buf, opt.ReportInterval, 0, stat, agg)
+	read := conn.Read
+
+	var v *verifier
+	if opt.Verify {
+		v = newReceiveVerifier(opt, streamDownload, udp)
+		read = verifyRead(read, v)
+	}
+
+	var loss *lossCounter
+	if udp && (opt.SeqNumbers || opt.Verify) {
+		loss = &lossCounter{}
+		read = lossRead(read, loss)
+	}
+
+	if !udp {
+		read = limitCall(read, opt.byteLimit(udp))
+	}
+
+	*result = workLoop(ctx, connIndex, "clientReader", "rcv/s", read, buf, opt.ReportInterval, opt.Omit, 0, stat, agg, tcpInfo)
+
+	if v != nil {
+		v.finish()
+		logIntegrity(ctx, "clientReader", connIndex, v.result)
+		agg.addIntegrity(v.result)
+	}
+
+	if loss != nil {
+		summaryf(ctx, "clientReader: %s loss: %d lost, %d received datagrams", connIndex, loss.lost(), loss.received)
+		agg.addLoss(*loss)
+	}
 
 	close(done)
 
-	log.Printf("clientReader: exiting: %d/%d %v", c, connections, conn.RemoteAddr())
+	debugf(ctx, "clientReader: exiting: %d/%d %v", c, connections, conn.RemoteAddr())
 }
 
-func clientWriter(ctx context.Context, conn net.Conn, c, connections int, done chan struct{}, bufSize int, opt Options, stat *ChartData, agg *aggregate) {
-	log.Printf("clientWriter: starting: %d/%d %v", c, connections, conn.RemoteAddr())
+func clientWriter(ctx context.Context, conn net.Conn, c, connections int, done chan struct{}, bufSize int, opt Options, stat *ChartData, result *loopResult, agg *aggregate, tcpInfo tcpInfoFunc, udp bool, sendFile string) {
+	debugf(ctx, "clientWriter: starting: %d/%d %v", c, connections, conn.RemoteAddr())
 
 	connIndex := fmt.Sprintf("%d/%d", c, connections)
 
-	buf := randBuf(bufSize)
+	buf := payloadBuf(opt, bufSize)
+
+	write := conn.Write
+	if opt.Verify {
+		write = verifyWrite(write, newVerifier(opt.Seed, streamUpload, udp))
+	}
+
+	var disk *diskStats
+	if sendFile != "" {
+		f, errOpen := os.Open(sendFile)
+		if errOpen != nil {
+			warnf(ctx, "clientWriter: %s: %v", connIndex, errOpen)
+			result.err = errOpen
+			close(done)
+			return
+		}
+		defer f.Close()
+		disk = &diskStats{}
+		write = fileSendCall(write, f, disk)
+	}
+
+	write = limitCall(write, opt.byteLimit(udp))
 
-	workLoop(ctx, connIndex, "clientWriter", "snd/s", conn.Write, buf, opt.ReportInterval, opt.MaxSpeed, stat, agg)
+	*result = workLoop(ctx, connIndex, "clientWriter", "snd/s", write, buf, opt.ReportInterval, opt.Omit, opt.MaxSpeed, stat, agg, tcpInfo)
+
+	if disk != nil {
+		reportDisk(ctx, "clientWriter", connIndex, "read", *disk)
+		agg.addDisk(*disk)
+	}
 
 	close(done)
 
-	log.Printf("clientWriter: exiting: %d/%d %v", c, connections, conn.RemoteAddr())
+	debugf(ctx, "clientWriter: exiting: %d/%d %v", c, connections, conn.RemoteAddr())
 }
 
 func randBuf(size int) []byte {
 	buf := make([]byte, size)
-	if _, err := rand.Read(buf); err != nil {
-		log.Printf("randBuf error: %v", err)
-	}
+	rand.Read(buf) // never fails, see crypto/rand.Read
 	return buf
 }
 
@@ -385,72 +770,186 @@ type account struct {
 	prevCalls int
 	size      int64
 	calls     int
+	tcpInfo   tcpInfoFunc // optional TCP_INFO sampler
+	hooks     *runHooks   // output destinations, nil means the global logger
+
+	// warm-up period excluded from averages and chart data
+	omitting  bool
+	omitUntil time.Time
+
+	// base for averages: start of measurement after warm-up
+	avgStart time.Time
+	avgSize  int64
+	avgCalls int
+}
+
+func newAccount(start time.Time, omit time.Duration) *account {
+	return &account{
+		prevTime:  start,
+		omitting:  omit > 0,
+		omitUntil: start.Add(omit),
+		avgStart:  start,
+	}
 }
 
 // ChartData records data for chart
 type ChartData struct {
 	XValues []time.Time
 	YValues []float64
+	TCPInfo []TCPInfo `yaml:"tcpinfo,omitempty"` // one sample per value, when available
 }
 
-const fmtReport = "%s %7s %14s rate: %f Mbps %6d %s"
-
 func (a *account) update(n int, reportInterval time.Duration, conn, label, cpsLabel string, stat *ChartData, forceUpdate bool) {
 	a.calls++
 	a.size += int64(n)
 
 	now := time.Now()
 	elap := now.Sub(a.prevTime)
-	if elap > reportInterval || forceUpdate {
+	omitEnd := a.omitting && !now.Before(a.omitUntil)
+	if elap > reportInterval || forceUpdate || omitEnd {
 		elapSec := elap.Seconds()
 		mbps := float64(8*(a.size-a.prevSize)) / (1000000 * elapSec)
 		cps := int64(float64(a.calls-a.prevCalls) / elapSec)
-		log.Printf(fmtReport, conn, "report", label, mbps, cps, cpsLabel)
+
+		kind := "report"
+		if a.omitting {
+			kind = "omit"
+		}
+
+		var ti TCPInfo
+		var tiOk bool
+		if a.tcpInfo != nil {
+			ti, tiOk = a.tcpInfo()
+		}
+		r := Report{Time: now, Conn: conn, Kind: kind, Label: label, Mbps: mbps, Cps: cps, CpsLabel: cpsLabel}
+		if tiOk {
+			r.TCPInfo = &ti
+		}
+		a.hooks.report(r)
 		a.prevTime = now
 		a.prevSize = a.size
 		a.prevCalls = a.calls
 
+		if a.omitting {
+			if omitEnd {
+				// warm-up is over: averages start from here
+				a.omitting = false
+				a.avgStart = now
+				a.avgSize = a.size
+				a.avgCalls = a.calls
+			}
+			return
+		}
+
 		// save chart data
 		if stat != nil {
 			stat.XValues = append(stat.XValues, now)
 			stat.YValues = append(stat.YValues, mbps)
+			if a.tcpInfo != nil {
+				stat.TCPInfo = append(stat.TCPInfo, ti) // keep aligned with XValues
+			}
 		}
 	}
 }
 
 type aggregate struct {
-	Mbps  float64 // Megabit/s
-	Cps   int64   // Call/s
-	Bytes int64   // total bytes
-	mutex sync.Mutex
+	Mbps        float64 // Megabit/s
+	Cps         int64   // Call/s
+	Bytes       int64   // total bytes
+	Retransmits uint64  // TCP retransmitted segments
+	mutex       sync.Mutex
+
+	retransmitsSampled bool // at least one connection sampled TCP_INFO
+
+	VerifiedBytes    int64
+	CorruptBytes     int64
+	CorruptDatagrams int64
+	MissingBytes     int64
+
+	Datagrams     int64 // UDP datagrams received with sequence numbers
+	LostDatagrams int64
+
+	DiskBytes int64   // file transfer bytes read from/written to disk
+	DiskMbps  float64 // disk-bound throughput, summed over connections like Mbps
+}
+
+func (agg *aggregate) addIntegrity(v integrity) {
+	agg.mutex.Lock()
+	agg.VerifiedBytes += v.Bytes
+	agg.CorruptBytes += v.CorruptBytes
+	agg.CorruptDatagrams += v.CorruptDatagrams
+	agg.MissingBytes += v.MissingBytes
+	agg.mutex.Unlock()
+}
+
+func (agg *aggregate) addLoss(l lossCounter) {
+	agg.mutex.Lock()
+	agg.Datagrams += l.received
+	agg.LostDatagrams += l.lost()
+	agg.mutex.Unlock()
+}
+
+func logIntegrity(ctx context.Context, label, connIndex string, v integrity) {
+	if v.Datagrams > 0 {
+		summaryf(ctx, "%s: %s verify: %d bytes checked, %d corrupted bytes, %d missing bytes, %d/%d corrupted datagrams",
+			label, connIndex, v.Bytes, v.CorruptBytes, v.MissingBytes, v.CorruptDatagrams, v.Datagrams)
+		return
+	}
+	summaryf(ctx, "%s: %s verify: %d bytes checked, %d corrupted bytes, %d missing bytes", label, connIndex, v.Bytes, v.CorruptBytes, v.MissingBytes)
+}
+
+func (agg *aggregate) addDisk(d diskStats) {
+	agg.mutex.Lock()
+	agg.DiskBytes += d.bytes
+	agg.DiskMbps += d.mbps()
+	agg.mutex.Unlock()
+}
+
+func (agg *aggregate) addRetransmits(r uint32) {
+	agg.mutex.Lock()
+	agg.Retransmits += uint64(r)
+	agg.retransmitsSampled = true
+	agg.mutex.Unlock()
 }
 
-func (a *account) average(start time.Time, conn, label, cpsLabel string, agg *aggregate) {
-	elapSec := time.Since(start).Seconds()
-	mbps := float64(8*a.size) / (1000000 * elapSec)
-	cps := int64(float64(a.calls) / elapSec)
-	log.Printf(fmtReport, conn, "average", label, mbps, cps, cpsLabel)
+func (a *account) average(conn, label, cpsLabel string, agg *aggregate) (float64, int64) {
+	if a.omitting {
+		// the warm-up period never ended: nothing to average
+		a.hooks.logf(context.Background(), slog.LevelWarn, "%s %s: no average, stopped within the omitted warm-up period", conn, label)
+		agg.mutex.Lock()
+		agg.Bytes += a.size
+		agg.mutex.Unlock()
+		return 0, 0
+	}
+
+	elapSec := time.Since(a.avgStart).Seconds()
+	mbps := float64(8*(a.size-a.avgSize)) / (1000000 * elapSec)
+	cps := int64(float64(a.calls-a.avgCalls) / elapSec)
+	a.hooks.report(Report{Time: time.Now(), Conn: conn, Kind: "average", Label: label, Mbps: mbps, Cps: cps, CpsLabel: cpsLabel})
 
 	agg.mutex.Lock()
 	agg.Mbps += mbps
 	agg.Cps += cps
 	agg.Bytes += a.size
 	agg.mutex.Unlock()
+
+	return mbps, cps
 }
 
-func workLoop(ctx context.Context, conn, label, cpsLabel string, f call, buf []byte, reportInterval time.Duration, maxSpeed float64, stat *ChartData, agg *aggregate) {
+func workLoop(ctx context.Context, conn, label, cpsLabel string, f call, buf []byte, reportInterval, omit time.Duration, maxSpeed float64, stat *ChartData, agg *aggregate, tcpInfo tcpInfoFunc) loopResult {
+
+	acc := newAccount(time.Now(), omit)
+	acc.tcpInfo = tcpInfo
+	acc.hooks = hooksFrom(ctx)
 
-	start := time.Now()
-	acc := &account{}
-	acc.prevTime = start
+	var errLoop error
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("workLoop: %s %s: shutdown requested", conn, label)
+			debugf(ctx, "workLoop: %s %s: shutdown requested", conn, label)
 			acc.update(0, reportInterval, conn, label, cpsLabel, stat, true)
-			acc.average(start, conn, label, cpsLabel, agg)
-			return
+			return acc.result(conn, label, cpsLabel, agg, nil)
 		default:
 		}
 
@@ -468,16 +967,35 @@ func workLoop(ctx context.Context, conn, label, cpsLabel string, f call, buf []b
 		}
 
 		n, errCall := f(buf)
+		if errors.Is(errCall, errLimitReached) {
+			debugf(ctx, "workLoop: %s %s: %v", conn, label, errCall)
+			acc.update(n, reportInterval, conn, label, cpsLabel, stat, true)
+			break
+		}
 		if errCall != nil {
-			log.Printf("workLoop: %s %s: %v n=%d", conn, label, errCall, n)
+			infof(ctx, "workLoop: %s %s: %v n=%d", conn, label, errCall, n)
 			acc.update(n, reportInterval, conn, label, cpsLabel, stat, true)
+			errLoop = errCall
 			break
 		}
 
 		acc.update(n, reportInterval, conn, label, cpsLabel, stat, false)
 	}
 
-	acc.average(start, conn, label, cpsLabel, agg)
+	return acc.result(conn, label, cpsLabel, agg, errLoop)
+}
+
+// result reports the average and returns the outcome of the loop.
+func (a *account) result(conn, label, cpsLabel string, agg *aggregate, err error) loopResult {
+	mbps, cps := a.average(conn, label, cpsLabel, agg)
+	return loopResult{
+		mbps:  mbps,
+		cps:   cps,
+		bytes: a.size,
+		calls: int64(a.calls),
+		err:   loopError(err),
+		end:   time.Now(),
+	}
 }
 
 // Remove semi colon, invalid use in filename on windows
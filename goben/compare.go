@@ -0,0 +1,208 @@
+package goben
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReadExport loads a file written by the yaml or csv export modes,
+// with rates converted to Mbps.
+func ReadExport(filename string) (*ExportInfo, error) {
+	data, errRead := os.ReadFile(filename)
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	var info ExportInfo
+	var err error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &info)
+	case ".csv":
+		err = parseExportCsv(data, &info)
+	default:
+		err = fmt.Errorf("unknown export format (expected .yaml, .yml or .csv)")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	u, errUnit := lookupRateUnit(info.Unit)
+	if errUnit != nil || u.mbps == 0 {
+		return nil, fmt.Errorf("%s: bad unit: %q", filename, info.Unit)
+	}
+	info.Unit = unitMbps.name
+	for _, data := range []*ChartData{&info.Input, &info.Output} {
+		for i := range data.YValues {
+			data.YValues[i] *= u.mbps
+		}
+	}
+	return &info, nil
+}
+
+// parseExportCsv parses the exportCsv format. Columns are found by name,
+// so files without the TCP_INFO or UNIT columns of older versions load too.
+func parseExportCsv(data []byte, info *ExportInfo) error {
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("empty csv")
+	}
+	col := map[string]int{}
+	for i, name := range rows[0] {
+		col[name] = i
+	}
+	for _, name := range []string{"DIRECTION", "TIME", "RATE"} {
+		if _, found := col[name]; !found {
+			return fmt.Errorf("missing csv column: %s", name)
+		}
+	}
+
+	for n, row := range rows[1:] {
+		var data *ChartData
+		switch row[col["DIRECTION"]] {
+		case "input":
+			data = &info.Input
+		case "output":
+			data = &info.Output
+		default:
+			return fmt.Errorf("line %d: bad direction: %q", n+2, row[col["DIRECTION"]])
+		}
+		t, errTime := parseTimeString(row[col["TIME"]])
+		if errTime != nil {
+			return fmt.Errorf("line %d: %w", n+2, errTime)
+		}
+		rate, errRate := strconv.ParseFloat(row[col["RATE"]], 64)
+		if errRate != nil {
+			return fmt.Errorf("line %d: %w", n+2, errRate)
+		}
+		data.XValues = append(data.XValues, t)
+		data.YValues = append(data.YValues, rate)
+		if i, found := col["UNIT"]; found {
+			info.Unit = row[i]
+		}
+	}
+	return nil
+}
+
+// parseTimeString parses the output of time.Time.String,
+// without its monotonic clock reading.
+func parseTimeString(s string) (time.Time, error) {
+	if i := strings.Index(s, " m="); i >= 0 {
+		s = s[:i]
+	}
+	return time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", s)
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating between ranks.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := min(lo+1, len(sorted)-1)
+	return sorted[lo] + (rank-float64(lo))*(sorted[hi]-sorted[lo])
+}
+
+// Comparison compares one metric of a baseline and a current run.
+type Comparison struct {
+	Direction  string  // input or output
+	Metric     string  // mean, p10, p50 or p90 of the interval rates
+	Baseline   float64 // Mbps
+	Current    float64 // Mbps
+	Change     float64 // relative change, -0.1 is 10% slower
+	Regression bool    // slower than the tolerance allows
+}
+
+type rateMetric struct {
+	name string
+	mbps float64
+}
+
+// rateMetrics returns the mean and percentiles of the interval rates.
+// Low percentiles show the slow intervals.
+func rateMetrics(data ChartData) []rateMetric {
+	sorted := slices.Sorted(slices.Values(data.YValues))
+	var mean float64
+	for _, v := range sorted {
+		mean += v
+	}
+	if len(sorted) > 0 {
+		mean /= float64(len(sorted))
+	}
+	return []rateMetric{
+		{"mean", mean},
+		{"p10", percentile(sorted, 10)},
+		{"p50", percentile(sorted, 50)},
+		{"p90", percentile(sorted, 90)},
+	}
+}
+
+// CompareExports compares the interval rates of two exports. A metric
+// regresses when current is slower than baseline by more than tolerance,
+// e.g. 0.05 for 5%. Directions without data in the baseline are skipped.
+func CompareExports(baseline, current *ExportInfo, tolerance float64) []Comparison {
+	var result []Comparison
+	for _, d := range []struct {
+		dir           string
+		base, current ChartData
+	}{{"input", baseline.Input, current.Input}, {"output", baseline.Output, current.Output}} {
+		if len(d.base.YValues) == 0 {
+			continue
+		}
+		base, cur := rateMetrics(d.base), rateMetrics(d.current)
+		for i, m := range base {
+			c := Comparison{
+				Direction: d.dir,
+				Metric:    m.name,
+				Baseline:  m.mbps,
+				Current:   cur[i].mbps,
+			}
+			if c.Baseline != 0 {
+				c.Change = (c.Current - c.Baseline) / c.Baseline
+			}
+			c.Regression = c.Current < c.Baseline*(1-tolerance)
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// WriteComparisonTable writes comparisons, with rates in unit, as in --unit.
+func WriteComparisonTable(w io.Writer, comparisons []Comparison, unit string) error {
+	u, errUnit := lookupRateUnit(unit)
+	if errUnit != nil {
+		return errUnit
+	}
+	var peak float64
+	for _, c := range comparisons {
+		peak = max(peak, c.Baseline, c.Current)
+	}
+	u = u.forValue(peak)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "DIRECTION\tMETRIC\tBASELINE %s\tCURRENT %s\tCHANGE\tSTATUS\n", u.name, u.name)
+	for _, c := range comparisons {
+		status := "ok"
+		if c.Regression {
+			status = "REGRESSION"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.2f\t%.2f\t%+.1f%%\t%s\n", c.Direction, c.Metric,
+			u.scale(c.Baseline), u.scale(c.Current), 100*c.Change, status)
+	}
+	return tw.Flush()
+}
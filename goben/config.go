@@ -1,9 +1,13 @@
 package goben
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 	"unicode"
@@ -12,14 +16,14 @@ import (
 )
 
 // Version is the current application version.
-const Version = "1.1.0"
+const Version = "1.2.0"
 
 // HostList holds a list of hosts to connect to or listen on.
 type HostList []string
 
 var (
-	reExportMode = regexp.MustCompile(`^(?i)(ascii|csv|yaml|png)$`)
-	reExportExt  = regexp.MustCompile(`(?i)\.(csv|yaml|yml|png|ascii)$`)
+	reExportMode = regexp.MustCompile(`^(?i)(ascii|csv|yaml|png|svg|html)$`)
+	reExportExt  = regexp.MustCompile(`(?i)\.(csv|yaml|yml|png|svg|html|ascii)$`)
 )
 
 // ExportTarget holds an export mode and its output filename.
@@ -28,7 +32,13 @@ type ExportTarget struct {
 	Filename string
 }
 
+// reportFilename is the default filename of the html report, one per run.
+const reportFilename = "goben-report.html"
+
 func defaultExportFilename(mode string) string {
+	if mode == "html" {
+		return reportFilename
+	}
 	return fmt.Sprintf("result-%%d-%%s.%s", mode)
 }
 
@@ -47,6 +57,10 @@ func parseExport(items []string) ([]ExportTarget, error) {
 
 		lower := strings.ToLower(item)
 
+		if lower == "none" {
+			continue
+		}
+
 		if reExportMode.MatchString(lower) {
 			mode := lower
 			targets = append(targets, ExportTarget{
@@ -70,7 +84,7 @@ func parseExport(items []string) ([]ExportTarget, error) {
 			continue
 		}
 
-		return nil, fmt.Errorf("unrecognized export item: %q (expected ascii, csv, yaml, png, or a filename ending in .csv, .yaml, .yml, .png, .ascii)", item)
+		return nil, fmt.Errorf("unrecognized export item: %q (expected ascii, csv, yaml, png, svg, html, none, or a filename ending in .csv, .yaml, .yml, .png, .svg, .html, .ascii)", item)
 	}
 
 	if len(targets) == 0 {
@@ -81,44 +95,144 @@ func parseExport(items []string) ([]ExportTarget, error) {
 
 // Config holds the configuration for the client and server.
 type Config struct {
-	Hosts          HostList
-	Listeners      HostList
-	DefaultPort    string
-	Connections    int
-	ReportInterval string
-	TotalDuration  string
-	Opt            Options
-	PassiveClient  bool
-	UDP            bool
-	Export         []string
-	exports        []ExportTarget
-	TLSCert        string
-	TLSKey         string
-	TLSCA          string
-	TLS            bool
-	TLSAuthClient  bool
-	TLSAuthServer  bool
-	TCP            bool
-	LocalAddr      string
+	Hosts              HostList
+	Listeners          HostList
+	DefaultPort        string
+	Connections        int
+	ReportInterval     string
+	TotalDuration      string
+	Omit               string
+	RampInterval       string
+	rampInterval       time.Duration
+	Opt                Options
+	payload            string // normalized Opt.Payload, sent to servers
+	PassiveClient      bool
+	UDP                bool
+	Export             []string
+	exports            []ExportTarget
+	ChartSize          string
+	chartWidth         int
+	chartHeight        int
+	ChartOverlay       bool
+	ChartSecondaryAxis bool
+	TLSCert            string
+	TLSKey             string
+	TLSCA              string
+	TLS                bool
+	TLSAuthClient      bool
+	TLSAuthServer      bool
+	TCP                bool
+	LocalAddr          string
+	DSCP               int
+	BindDevice         string
+	LimitTotal         bool
+	SendFile           string
+	WriteDir           string
+	Reporter           string
+	Live               bool
+	Verbose            bool
+	Quiet              bool
+	LogFormat          string
+	Unit               string
+	unit               rateUnit
+	ConfigFile         string
+	DumpConfig         bool
+	HostOverrides      map[string]HostOverride // per Hosts entry, see LoadConfigFile
+	Scenarios          string
+	Repeat             int
+	RepeatPause        string
+	repeatPause        time.Duration
+	MinMbps            float64
+	MaxLoss            string
+	maxLoss            float64 // percent, negative disables
+	MaxRTT             string
+	maxRTT             time.Duration
+	exportPrefix       string // scenario export filename prefix
+	targets            []hostTarget
+
+	// set by the Client and Server API
+	logger   *slog.Logger // nil means the global logger
+	output   io.Writer    // reporter and ASCII chart output, nil means os.Stdout
+	reporter Reporter     // nil means the one named by Reporter
+	onReport func(Report) // optional report callback
+	addrs    []net.Addr   // server listening addresses
+}
+
+// hooks returns the output destinations for a run of this config.
+func (app *Config) hooks() *runHooks {
+	h := newRunHooks(app.logger, app.reporter)
+	if app.onReport != nil {
+		h.reporter = multiReporter{h.reporter, ReportFunc(app.onReport)}
+	}
+	return h
+}
+
+func (app *Config) errorf(format string, v ...any) {
+	app.hooks().logf(context.Background(), slog.LevelError, format, v...)
+}
+
+// hostTarget is a parsed --hosts entry.
+type hostTarget struct {
+	host        string   // host:port
+	localAddr   net.Addr // optional source address
+	connections int
+	maxSpeed    float64 // Mbps
+}
+
+// totalConnections returns the number of connections to all hosts.
+func (app *Config) totalConnections() int {
+	var total int
+	for _, t := range app.targets {
+		total += t.connections
+	}
+	return total
 }
 
 // AssignFlags parses command line flags.
 func (app *Config) AssignFlags(flagset *pflag.FlagSet) {
-	flagset.VarP(&app.Hosts, "hosts", "H", "comma-separated list of target hosts for client mode\nformat: host[:port] (port defaults to --defaultPort)")
+	flagset.StringVar(&app.ConfigFile, "config", "", "load options from this YAML file, keys are flag names\nprecedence: command line, then GOBEN_* environment variables (e.g. GOBEN_TCP_READ_SIZE), then this file\nhosts entries may override connections, maxSpeed and localAddr, see README")
+	flagset.StringVar(&app.Scenarios, "scenarios", "", "run the test variants of this YAML file one after the other against --hosts, then print a table comparing them\nexports of each scenario are prefixed with its name, see README")
+	flagset.IntVar(&app.Repeat, "repeat", 1, "run the test this many times, then report mean, median, stddev and 95% confidence interval of the per-run averages")
+	flagset.StringVar(&app.RepeatPause, "repeatPause", "0s", "pause between repeated runs\nunspecified time unit defaults to second")
+	flagset.Var(newRateValue(0, &app.MinMbps), "minMbps", "threshold: fail unless the aggregate rate reaches this in each direction with traffic, units as in --maxSpeed (0 disables)\nthe test prints PASS or FAIL and goben exits with status 3 on FAIL\nwith --repeat, each run is checked on its own and one FAIL fails the whole command")
+	flagset.StringVar(&app.MaxLoss, "maxLoss", "", "threshold: fail if more than this percentage of UDP datagrams from the server is lost, example: 0.5% (empty disables)\nthe server stamps datagrams with sequence numbers, it must run goben 1.2.0 or later, which confirms them in its ack, or the test fails")
+	flagset.StringVar(&app.MaxRTT, "maxRTT", "0s", "threshold: fail if the average TCP round-trip time exceeds this, from TCP_INFO, linux only (0 disables)\nunspecified time unit defaults to second")
+	flagset.BoolVar(&app.DumpConfig, "dumpConfig", false, "print the effective options in --config format, then exit")
+	flagset.VarP(&app.Hosts, "hosts", "H", "comma-separated list of target hosts for client mode\nformat: host[:port][@localAddr[:port]] (port defaults to --defaultPort)\nexample: --hosts 10.0.0.1@192.168.1.10,10.0.0.2@192.168.2.10")
 	flagset.VarP(&app.Listeners, "listeners", "l", "comma-separated list of listen addresses for server mode\nformat: [host]:port")
 	flagset.StringVarP(&app.DefaultPort, "defaultPort", "p", ":8080", "default port, automatically appended to hosts without explicit port")
 	flagset.IntVarP(&app.Connections, "connections", "c", 1, "number of parallel connections to each host")
 	flagset.StringVarP(&app.ReportInterval, "reportInterval", "i", "2s", "periodic throughput report interval\nunspecified time unit defaults to second")
 	flagset.StringVarP(&app.TotalDuration, "totalDuration", "d", "10s", "total test duration\nunspecified time unit defaults to second")
-	flagset.IntVar(&app.Opt.TCPReadSize, "tcpReadSize", 1000000, "TCP read buffer size in bytes")
-	flagset.IntVar(&app.Opt.TCPWriteSize, "tcpWriteSize", 1000000, "TCP write buffer size in bytes")
-	flagset.IntVar(&app.Opt.UDPReadSize, "udpReadSize", 64000, "UDP read buffer size in bytes")
-	flagset.IntVar(&app.Opt.UDPWriteSize, "udpWriteSize", 64000, "UDP write buffer size in bytes")
+	flagset.Var(newSizeValue(0, &app.Opt.Bytes), "bytes", "bytes to transfer in each direction per connection, then stop (0 means unlimited)\nunit suffixes: K, M, G (decimal) or Ki, Mi, Gi (binary), optional B, example: 1.5GB\n--totalDuration still bounds the test, use -d 0 to disable the time limit")
+	flagset.Int64Var(&app.Opt.Blocks, "blocks", 0, "blocks (write calls of tcpWriteSize/udpWriteSize bytes) to transfer in each direction per connection (0 means unlimited)")
+	flagset.BoolVar(&app.LimitTotal, "limitTotal", false, "--bytes and --blocks are totals split evenly across all connections to all hosts")
+	flagset.StringVar(&app.SendFile, "sendFile", "", "client streams this file instead of the payload, the test ends when the file is sent (TCP only)\nthe server sends back as many bytes unless --passiveServer")
+	flagset.StringVar(&app.WriteDir, "writeDir", "", "server writes received data to one file per connection in this directory, or to an existing file such as /dev/null")
+	flagset.StringVar(&app.Opt.Payload, "payload", payloadRandom, "payload generator for sent data, also used by the server: random, zeros, text, compressible:RATIO, file:PATH\nRATIO from 0 (incompressible) to 1 (all zeros); file contents are repeated to fill each write\nexample: --payload compressible:0.75")
+	flagset.BoolVar(&app.Opt.Verify, "verify", false, "send a deterministic pseudo-random stream and verify received data, reporting corrupted and missing bytes/datagrams")
+	flagset.Int64Var(&app.Opt.Seed, "seed", 0, "seed for the --verify stream (0 picks a random seed)")
+	flagset.StringVar(&app.Omit, "omit", "0s", "omit the first warm-up period of each connection from averages and charts\nunspecified time unit defaults to second")
+	flagset.StringVar(&app.RampInterval, "rampInterval", "0s", "delay between starting successive connections (0 starts all at once)\nunspecified time unit defaults to second")
+	flagset.Var(newSizeValue(1000000, &app.Opt.TCPReadSize), "tcpReadSize", "TCP read buffer size in bytes, unit suffixes as in --bytes")
+	flagset.Var(newSizeValue(1000000, &app.Opt.TCPWriteSize), "tcpWriteSize", "TCP write buffer size in bytes, unit suffixes as in --bytes")
+	flagset.Var(newSizeValue(64000, &app.Opt.UDPReadSize), "udpReadSize", "UDP read buffer size in bytes, unit suffixes as in --bytes")
+	flagset.Var(newSizeValue(64000, &app.Opt.UDPWriteSize), "udpWriteSize", "UDP write buffer size in bytes, unit suffixes as in --bytes")
 	flagset.BoolVar(&app.PassiveClient, "passiveClient", false, "suppress client traffic (receive only)")
 	flagset.BoolVar(&app.Opt.PassiveServer, "passiveServer", false, "suppress server traffic (receive only)")
-	flagset.Float64VarP(&app.Opt.MaxSpeed, "maxSpeed", "m", 0, "bandwidth limit in Mbps (0 means unlimited)")
+	flagset.BoolVar(&app.Opt.SyncStart, "syncStart", true, "wait until all connections to all hosts are established, then start and stop them together\nwith --rampInterval, connections start at staggered offsets of the common schedule\nolder servers, which do not confirm the start message, start their timers at connection")
+	flagset.VarP(newRateValue(0, &app.Opt.MaxSpeed), "maxSpeed", "m", "bandwidth limit, a number in Mbps or a rate with unit (0 means unlimited)\nexample: --maxSpeed 500M, 2.5Gbps, 100Kbps or 10MB/s")
 	flagset.BoolVarP(&app.UDP, "udp", "u", false, "use UDP protocol instead of TCP")
-	flagset.StringSliceVarP(&app.Export, "export", "e", nil, "export mode: comma-separated or repeated flags of ascii, csv, yaml, png, or filenames with recognized extensions\nexample: --export ascii,csv,result-%d-%s.yaml or -e my.yaml -e my.png")
+	flagset.BoolVarP(&app.Verbose, "verbose", "v", false, "log debug messages: goroutines, options, certificates")
+	flagset.BoolVarP(&app.Quiet, "quiet", "q", false, "log only warnings, errors and final results")
+	flagset.StringVar(&app.LogFormat, "logFormat", logFormatClassic, "log format: classic, text (slog key=value) or json")
+	flagset.StringVar(&app.Unit, "unit", unitMbps.name, "rate unit for reports, summary and exports: bps, Kbps, Mbps, Gbps, Tbps, bytes/s, KB/s, MB/s, GB/s or auto (scaled bit rate)")
+	flagset.StringVar(&app.Reporter, "reporter", reporterLog, "progress and summary reporting: log (classic log lines), table (compact table on stdout), json (JSON lines on stdout) or none")
+	flagset.BoolVar(&app.Live, "live", false, "redraw a dashboard of connection rates and sparklines on the terminal, instead of logging reports\nfalls back to --reporter when stdout is not a terminal")
+	flagset.StringSliceVarP(&app.Export, "export", "e", nil, "export mode: comma-separated or repeated flags of ascii, csv, yaml, png, svg, html, none, or filenames with recognized extensions\nhtml is one report per run, with summary and charts of all connections (default "+reportFilename+")\nexample: --export ascii,csv,result-%d-%s.yaml or -e my.yaml -e my.png")
+	flagset.StringVar(&app.ChartSize, "chartSize", defaultChartSize, "size of png and svg charts in pixels, WIDTHxHEIGHT")
+	flagset.BoolVar(&app.ChartOverlay, "chartOverlay", false, "with png or svg export, also render one chart overlaying all connections and their aggregate\nexample: result-%d-%s.png adds result-all-overlay.png")
+	flagset.BoolVar(&app.ChartSecondaryAxis, "chartSecondaryAxis", false, "plot output rates on their own right-hand axis, instead of sharing the axis with input rates")
 	flagset.StringVar(&app.TLSKey, "key", "key.pem", "TLS private key file (PEM format)")
 	flagset.StringVar(&app.TLSCert, "cert", "cert.pem", "TLS certificate file (PEM format)")
 	flagset.StringVar(&app.TLSCA, "ca", "ca.pem", "TLS CA certificate file for peer verification (PEM format)")
@@ -126,7 +240,10 @@ func (app *Config) AssignFlags(flagset *pflag.FlagSet) {
 	flagset.BoolVar(&app.TLSAuthClient, "tlsAuthClient", true, "enable mutual TLS: verify server certificate against CA")
 	flagset.BoolVar(&app.TLSAuthServer, "tlsAuthServer", true, "enable mutual TLS: verify client certificate against CA")
 	flagset.BoolVarP(&app.TCP, "tcp", "t", true, "enable TCP transport (disable to test TLS-only or UDP-only)")
-	flagset.StringVarP(&app.LocalAddr, "localAddr", "a", "", "bind specific local address:port\nexample: --localAddr 127.0.0.1:2000")
+	flagset.StringVarP(&app.LocalAddr, "localAddr", "a", "", "bind specific local address[:port] for hosts without their own @localAddr\nexample: --localAddr 127.0.0.1:2000")
+	flagset.StringVar(&app.BindDevice, "bindDevice", "", "bind sockets to network interface or VRF device (SO_BINDTODEVICE, linux only)\nexample: --bindDevice eth1")
+	flagset.IntVar(&app.Opt.TOS, "tos", 0, "IP TOS byte / IPv6 traffic class for test traffic, 0-255 (0 means unchanged)\nalso applied by the server to its sockets, unless the client requests its own")
+	flagset.IntVar(&app.DSCP, "dscp", 0, "DSCP codepoint for test traffic, 0-63 (shorthand for --tos DSCP<<2)\nexample: --dscp 46 (EF)")
 }
 
 // NewDefaultConfig creates a config with default values
@@ -159,6 +276,23 @@ func (h *HostList) Type() string {
 	return "strings"
 }
 
+// Append adds one host, for pflag.SliceValue.
+func (h *HostList) Append(value string) error {
+	*h = append(*h, value)
+	return nil
+}
+
+// Replace replaces all hosts, for pflag.SliceValue.
+func (h *HostList) Replace(values []string) error {
+	*h = append(HostList{}, values...)
+	return nil
+}
+
+// GetSlice returns the hosts, for pflag.SliceValue.
+func (h *HostList) GetSlice() []string {
+	return append([]string{}, *h...)
+}
+
 func badExportFilename(parameter, filename string) error {
 	if filename == "" {
 		return nil
@@ -174,14 +308,24 @@ func badExportFilename(parameter, filename string) error {
 // ValidateAndUpdateConfig validates and updates the config
 // it will set internal values necessary for successful completion
 func ValidateAndUpdateConfig(app *Config) error {
+	if errLogger := updateLogger(app); errLogger != nil {
+		app.errorf("%s", errLogger.Error())
+		return errLogger
+	}
+
 	targets, errParse := parseExport(app.Export)
 	if errParse != nil {
 		return fmt.Errorf("invalid --export value: %w", errParse)
 	}
 	for _, t := range targets {
+		if t.Mode == "html" && strings.Contains(t.Filename, "%") {
+			err := fmt.Errorf("bad --export filename: %s: the html report is one file per run, without '%%d' or '%%s'", t.Filename)
+			app.errorf("%s", err.Error())
+			return err
+		}
 		if strings.Contains(t.Filename, "%") {
 			if err := badExportFilename("--export", t.Filename); err != nil {
-				log.Printf("%s", err.Error())
+				app.errorf("%s", err.Error())
 				return err
 			}
 		}
@@ -190,21 +334,102 @@ func ValidateAndUpdateConfig(app *Config) error {
 
 	app.ReportInterval = defaultTimeUnit(app.ReportInterval)
 	app.TotalDuration = defaultTimeUnit(app.TotalDuration)
+	app.Omit = defaultTimeUnit(app.Omit)
+	app.RampInterval = defaultTimeUnit(app.RampInterval)
+	app.RepeatPause = defaultTimeUnit(app.RepeatPause)
+	app.MaxRTT = defaultTimeUnit(app.MaxRTT)
 
 	var errInterval error
 	app.Opt.ReportInterval, errInterval = time.ParseDuration(app.ReportInterval)
 	if errInterval != nil {
-		log.Printf("bad reportInterval: %q: %v", app.ReportInterval, errInterval)
+		app.errorf("bad reportInterval: %q: %v", app.ReportInterval, errInterval)
 		return errInterval
 	}
 
 	var errDuration error
 	app.Opt.TotalDuration, errDuration = time.ParseDuration(app.TotalDuration)
 	if errDuration != nil {
-		log.Printf("bad totalDuration: %q: %v", app.TotalDuration, errDuration)
+		app.errorf("bad totalDuration: %q: %v", app.TotalDuration, errDuration)
 		return errDuration
 	}
 
+	if errUnit := updateUnit(app); errUnit != nil {
+		app.errorf("%s", errUnit.Error())
+		return errUnit
+	}
+
+	if errReporter := updateReporter(app); errReporter != nil {
+		app.errorf("%s", errReporter.Error())
+		return errReporter
+	}
+
+	if errChart := updateChartSize(app); errChart != nil {
+		app.errorf("%s", errChart.Error())
+		return errChart
+	}
+
+	if errFile := updateSendFile(app); errFile != nil {
+		app.errorf("%s", errFile.Error())
+		return errFile
+	}
+
+	if errDir := validateWriteDir(app.WriteDir); errDir != nil {
+		app.errorf("%s", errDir.Error())
+		return errDir
+	}
+
+	if errLimit := validateLimit(app); errLimit != nil {
+		app.errorf("%s", errLimit.Error())
+		return errLimit
+	}
+
+	if errPayload := updatePayload(app); errPayload != nil {
+		app.errorf("%s", errPayload.Error())
+		return errPayload
+	}
+
+	if errOmit := parseOmit(app); errOmit != nil {
+		app.errorf("%s", errOmit.Error())
+		return errOmit
+	}
+
+	if errRamp := parseRampInterval(app); errRamp != nil {
+		app.errorf("%s", errRamp.Error())
+		return errRamp
+	}
+
+	if errRepeat := parseRepeat(app); errRepeat != nil {
+		app.errorf("%s", errRepeat.Error())
+		return errRepeat
+	}
+
+	if errThresholds := parseThresholds(app); errThresholds != nil {
+		app.errorf("%s", errThresholds.Error())
+		return errThresholds
+	}
+
+	if errTOS := updateTOS(app); errTOS != nil {
+		app.errorf("%s", errTOS.Error())
+		return errTOS
+	}
+
+	if errDevice := validateBindDevice(app.BindDevice); errDevice != nil {
+		app.errorf("%s", errDevice.Error())
+		return errDevice
+	}
+
+	hosts, errHosts := parseHosts(app)
+	if errHosts != nil {
+		app.errorf("%s", errHosts.Error())
+		return errHosts
+	}
+	app.targets = hosts
+
+	if errRamp := validateRampSchedule(app); errRamp != nil {
+		app.errorf("%s", errRamp.Error())
+		return errRamp
+	}
+
 	if len(app.Listeners) == 0 {
 		app.Listeners = []string{app.DefaultPort}
 	}
@@ -212,6 +437,183 @@ func ValidateAndUpdateConfig(app *Config) error {
 	return nil
 }
 
+func validateBindDevice(device string) error {
+	if device == "" {
+		return nil
+	}
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("bad bindDevice: %q: only supported on linux", device)
+	}
+	if _, err := net.InterfaceByName(device); err != nil {
+		return fmt.Errorf("bad bindDevice: %q: %w", device, err)
+	}
+	return nil
+}
+
+// parseHosts splits host[:port][@localAddr] entries and resolves local addresses.
+// Hosts without their own local address use --localAddr, if any.
+func parseHosts(app *Config) ([]hostTarget, error) {
+	proto := "tcp"
+	if app.UDP {
+		proto = "udp"
+	}
+
+	targets := make([]hostTarget, 0, len(app.Hosts))
+
+	for _, h := range app.Hosts {
+		host, local := h, app.LocalAddr
+		if i := strings.LastIndex(h, "@"); i >= 0 {
+			host, local = h[:i], h[i+1:]
+			if local == "" {
+				return nil, fmt.Errorf("bad host: %q: empty local address after '@'", h)
+			}
+		}
+
+		t := hostTarget{
+			host:        appendPortIfMissing(host, app.DefaultPort),
+			connections: app.Connections,
+			maxSpeed:    app.Opt.MaxSpeed,
+		}
+		if o, found := app.HostOverrides[h]; found {
+			if o.Connections < 0 || o.MaxSpeed < 0 {
+				return nil, fmt.Errorf("bad host override: %q: negative value: %+v", h, o)
+			}
+			if o.Connections > 0 {
+				t.connections = o.Connections
+			}
+			if o.MaxSpeed > 0 {
+				t.maxSpeed = o.MaxSpeed
+			}
+		}
+
+		if local != "" {
+			addr, errAddr := resolveLocalAddr(proto, local)
+			if errAddr != nil {
+				return nil, fmt.Errorf("bad local address for host %q: %w", host, errAddr)
+			}
+			t.localAddr = addr
+		}
+
+		targets = append(targets, t)
+	}
+
+	return targets, nil
+}
+
+// resolveLocalAddr resolves a local address, port defaults to 0 (any).
+func resolveLocalAddr(proto, addr string) (net.Addr, error) {
+	addr = appendPortIfMissing(addr, ":0")
+	if proto == "udp" {
+		return net.ResolveUDPAddr(proto, addr)
+	}
+	return net.ResolveTCPAddr(proto, addr)
+}
+
+func validateLimit(app *Config) error {
+	if app.Opt.Bytes < 0 || app.Opt.Blocks < 0 {
+		return fmt.Errorf("bad limit: bytes=%d blocks=%d: negative value", app.Opt.Bytes, app.Opt.Blocks)
+	}
+	if app.Opt.Bytes > 0 && app.Opt.Blocks > 0 {
+		return fmt.Errorf("bad limit: bytes=%d blocks=%d: use only one of --bytes or --blocks", app.Opt.Bytes, app.Opt.Blocks)
+	}
+	if app.Opt.TotalDuration < 0 || (app.Opt.TotalDuration == 0 && !app.Opt.hasLimit()) {
+		return fmt.Errorf("bad totalDuration: %v: must be positive unless --bytes or --blocks is set", app.Opt.TotalDuration)
+	}
+	if app.Opt.TotalDuration == 0 && app.UDP && app.PassiveClient {
+		return fmt.Errorf("bad totalDuration: %v: must be positive with --udp --passiveClient, which cannot detect the end of a --bytes or --blocks transfer", app.Opt.TotalDuration)
+	}
+	return nil
+}
+
+func updatePayload(app *Config) error {
+	// file contents travel in the options, which must fit a single UDP datagram
+	maxSize := app.Opt.TCPWriteSize
+	if app.UDP {
+		maxSize = min(app.Opt.UDPWriteSize, payloadFileMaxUDP)
+	}
+	payload, data, err := parsePayload(app.Opt.Payload, maxSize)
+	if err != nil {
+		return err
+	}
+	if app.Opt.Verify && payload != payloadRandom {
+		return fmt.Errorf("bad payload: %q: --verify generates its own pseudo-random payload", app.Opt.Payload)
+	}
+	app.payload = payload
+	app.Opt.PayloadData = data
+	return nil
+}
+
+func parseOmit(app *Config) error {
+	if app.Omit == "" {
+		app.Opt.Omit = 0
+		return nil
+	}
+	omit, err := time.ParseDuration(app.Omit)
+	if err != nil {
+		return fmt.Errorf("bad omit: %q: %w", app.Omit, err)
+	}
+	if omit < 0 {
+		return fmt.Errorf("bad omit: %q: negative duration", app.Omit)
+	}
+	if omit > 0 && app.Opt.TotalDuration > 0 && omit >= app.Opt.TotalDuration {
+		return fmt.Errorf("bad omit: %q: must be shorter than totalDuration=%v", app.Omit, app.Opt.TotalDuration)
+	}
+	app.Opt.Omit = omit
+	return nil
+}
+
+func parseRampInterval(app *Config) error {
+	if app.RampInterval == "" {
+		app.rampInterval = 0
+		return nil
+	}
+	ramp, err := time.ParseDuration(app.RampInterval)
+	if err != nil {
+		return fmt.Errorf("bad rampInterval: %q: %w", app.RampInterval, err)
+	}
+	if ramp < 0 {
+		return fmt.Errorf("bad rampInterval: %q: negative duration", app.RampInterval)
+	}
+	app.rampInterval = ramp
+	return nil
+}
+
+// validateRampSchedule checks that, with synchronized start,
+// the last ramped up connection starts, and ends its warm-up period,
+// before the common deadline.
+func validateRampSchedule(app *Config) error {
+	if !app.Opt.SyncStart || app.rampInterval == 0 || len(app.targets) == 0 || app.Opt.TotalDuration == 0 {
+		return nil
+	}
+	last := time.Duration(app.totalConnections()-1) * app.rampInterval
+	if last >= app.Opt.TotalDuration {
+		return fmt.Errorf("bad rampInterval: %v: last connection would start at %v, after totalDuration=%v", app.rampInterval, last, app.Opt.TotalDuration)
+	}
+	if last+app.Opt.Omit >= app.Opt.TotalDuration {
+		return fmt.Errorf("bad rampInterval: %v: last connection would start at %v and omit %v, after totalDuration=%v", app.rampInterval, last, app.Opt.Omit, app.Opt.TotalDuration)
+	}
+	return nil
+}
+
+// updateTOS merges --dscp into the TOS option sent to the server.
+func updateTOS(app *Config) error {
+	if app.DSCP < 0 || app.DSCP > 63 {
+		return fmt.Errorf("bad dscp: %d (expected 0-63)", app.DSCP)
+	}
+	if app.Opt.TOS < 0 || app.Opt.TOS > 255 {
+		return fmt.Errorf("bad tos: %d (expected 0-255)", app.Opt.TOS)
+	}
+	if app.DSCP == 0 {
+		return nil
+	}
+	tos := app.DSCP << 2
+	if app.Opt.TOS != 0 && app.Opt.TOS != tos {
+		return fmt.Errorf("conflicting dscp=%d and tos=%d", app.DSCP, app.Opt.TOS)
+	}
+	app.Opt.TOS = tos
+	return nil
+}
+
 // ValidateAndUpdateServerConfig validates and updates the config.
 //
 // Deprecated: Use ValidateAndUpdateConfig instead, which supersedes this function.
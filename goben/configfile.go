@@ -0,0 +1,245 @@
+package goben
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// HostOverride holds per-host settings from the config file,
+// zero values mean the global setting.
+type HostOverride struct {
+	Connections int
+	MaxSpeed    float64 // Mbps
+}
+
+// flags that only control the config file itself
+var configFileFlags = map[string]bool{"config": true, "dumpConfig": true}
+
+// LoadConfigFile sets the options in the --config YAML file, whose keys are
+// the flag names, skipping flags set on the command line or from the environment.
+// flagset must hold the flags of app, see AssignFlags.
+//
+// Besides host strings, hosts entries may be mappings with keys host,
+// localAddr, connections and maxSpeed, for per-host overrides.
+func (app *Config) LoadConfigFile(flagset *pflag.FlagSet) error {
+	if app.ConfigFile == "" {
+		return nil
+	}
+	data, errRead := os.ReadFile(app.ConfigFile)
+	if errRead != nil {
+		return fmt.Errorf("config: %w", errRead)
+	}
+	if err := app.loadConfig(flagset, data); err != nil {
+		return fmt.Errorf("config: %s: %w", app.ConfigFile, err)
+	}
+	return nil
+}
+
+func (app *Config) loadConfig(flagset *pflag.FlagSet, data []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil // empty file
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping of option names to values", root.Line)
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		name := key.Value
+		f := flagset.Lookup(name)
+		if f == nil || configFileFlags[name] {
+			return fmt.Errorf("line %d: unknown option: %q", key.Line, name)
+		}
+		if f.Changed {
+			continue // flags take precedence
+		}
+		var err error
+		if name == "hosts" && value.Kind == yaml.SequenceNode {
+			err = app.loadHosts(f, value)
+		} else {
+			err = setOption(flagset, f, value)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", value.Line, name, err)
+		}
+	}
+	return nil
+}
+
+// setOption sets a flag from a YAML value or list of values.
+func setOption(flagset *pflag.FlagSet, f *pflag.Flag, value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		return flagset.Set(f.Name, value.Value)
+	case yaml.SequenceNode:
+		return setSlice(f, value)
+	}
+	return fmt.Errorf("expected a value or a list")
+}
+
+func setSlice(f *pflag.Flag, node *yaml.Node) error {
+	slice, isSlice := f.Value.(pflag.SliceValue)
+	if !isSlice {
+		return fmt.Errorf("expected a single value, not a list")
+	}
+	items := make([]string, 0, len(node.Content))
+	for _, n := range node.Content {
+		if n.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: expected a list of values", n.Line)
+		}
+		items = append(items, n.Value)
+	}
+	if err := slice.Replace(items); err != nil {
+		return err
+	}
+	f.Changed = true
+	return nil
+}
+
+// loadHosts loads the hosts list, with optional per-host overrides.
+func (app *Config) loadHosts(f *pflag.Flag, node *yaml.Node) error {
+	var hosts []string
+	overrides := map[string]HostOverride{}
+	seen := map[string]HostOverride{}
+	for _, n := range node.Content {
+		var host string
+		var override HostOverride
+		switch n.Kind {
+		case yaml.ScalarNode:
+			host = n.Value
+		case yaml.MappingNode:
+			var err error
+			host, override, err = parseHostOverride(n)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("line %d: expected a host or a mapping", n.Line)
+		}
+		// overrides are kept by host, so repeated hosts must agree
+		if previous, found := seen[host]; found && previous != override {
+			return fmt.Errorf("line %d: duplicate host with different overrides: %q", n.Line, host)
+		}
+		seen[host] = override
+		hosts = append(hosts, host)
+		if override != (HostOverride{}) {
+			overrides[host] = override
+		}
+	}
+	if err := f.Value.(pflag.SliceValue).Replace(hosts); err != nil {
+		return err
+	}
+	f.Changed = true
+	app.HostOverrides = overrides
+	return nil
+}
+
+// parseHostOverride parses a hosts entry mapping, returning the host
+// in --hosts format: host[:port][@localAddr[:port]].
+func parseHostOverride(node *yaml.Node) (string, HostOverride, error) {
+	var host, local string
+	var override HostOverride
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		var err error
+		switch key.Value {
+		case "host":
+			host = value.Value
+		case "localAddr":
+			local = value.Value
+		case "connections":
+			override.Connections, err = strconv.Atoi(value.Value)
+			if err == nil && override.Connections < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "maxSpeed":
+			override.MaxSpeed, err = parseRate(value.Value)
+		default:
+			err = fmt.Errorf("unknown host option (expected host, localAddr, connections or maxSpeed)")
+		}
+		if err != nil {
+			return "", override, fmt.Errorf("line %d: %s: %w", key.Line, key.Value, err)
+		}
+	}
+	if host == "" {
+		return "", override, fmt.Errorf("line %d: missing host", node.Line)
+	}
+	if local != "" {
+		host += "@" + local
+	}
+	return host, override, nil
+}
+
+// WriteConfig writes the effective options in the --config YAML format.
+// flagset must hold the flags of app, see AssignFlags.
+func (app *Config) WriteConfig(w io.Writer, flagset *pflag.FlagSet) error {
+	doc := map[string]any{}
+	flagset.VisitAll(func(f *pflag.Flag) {
+		if configFileFlags[f.Name] {
+			return
+		}
+		doc[f.Name] = flagYAML(f)
+	})
+
+	var hosts []any
+	for _, h := range app.Hosts {
+		override, found := app.HostOverrides[h]
+		if !found {
+			hosts = append(hosts, h)
+			continue
+		}
+		entry := map[string]any{"host": h}
+		if i := strings.LastIndex(h, "@"); i >= 0 {
+			entry["host"], entry["localAddr"] = h[:i], h[i+1:]
+		}
+		if override.Connections > 0 {
+			entry["connections"] = override.Connections
+		}
+		if override.MaxSpeed > 0 {
+			entry["maxSpeed"] = override.MaxSpeed
+		}
+		hosts = append(hosts, entry)
+	}
+	doc["hosts"] = hosts
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// flagYAML returns the value of a flag with its YAML type.
+func flagYAML(f *pflag.Flag) any {
+	if slice, isSlice := f.Value.(pflag.SliceValue); isSlice {
+		return slice.GetSlice()
+	}
+	s := f.Value.String()
+	switch f.Value.Type() {
+	case "bool":
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case "int", "int64", "size":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case "rate":
+		if v, err := strconv.ParseFloat(s, 64); err == nil {
+			return v
+		}
+	}
+	return s
+}
@@ -8,9 +8,13 @@ import (
 
 // CSV fields
 const (
-	Dir  = 0 // Direction
-	Time = 1 // Timestamp
-	Rate = 2 // Rate
+	Dir        = 0 // Direction
+	Time       = 1 // Timestamp
+	Rate       = 2 // Rate
+	csvRTT     = 3 // TCP round-trip time (empty when unavailable)
+	csvCwnd    = 4 // TCP congestion window (empty when unavailable)
+	csvRetrans = 5 // TCP total retransmits (empty when unavailable)
+	csvUnit    = 6 // Rate unit
 )
 
 func exportCsv(filename string, info *ExportInfo) error {
@@ -22,31 +26,43 @@ func exportCsv(filename string, info *ExportInfo) error {
 
 	w := csv.NewWriter(out)
 
-	entry := []string{"DIRECTION", "TIME", "RATE"}
+	entry := []string{"DIRECTION", "TIME", "RATE", "RTT", "CWND", "RETRANS", "UNIT"}
 
 	if errHeader := w.Write(entry); errHeader != nil {
 		return errHeader
 	}
 
+	entry[csvUnit] = info.Unit
+
 	entry[Dir] = "input"
-	for i, x := range info.Input.XValues {
-		entry[Time] = x.String()
-		entry[Rate] = fmt.Sprintf("%v", info.Input.YValues[i])
-		if err := w.Write(entry); err != nil {
-			return err
-		}
+	if err := writeCsvSeries(w, entry, &info.Input); err != nil {
+		return err
 	}
 
 	entry[Dir] = "output"
-	for i, x := range info.Output.XValues {
-		entry[Time] = x.String()
-		entry[Rate] = fmt.Sprintf("%v", info.Output.YValues[i])
-		if err := w.Write(entry); err != nil {
-			return err
-		}
+	if err := writeCsvSeries(w, entry, &info.Output); err != nil {
+		return err
 	}
 
 	w.Flush()
 
 	return out.Close()
 }
+
+func writeCsvSeries(w *csv.Writer, entry []string, data *ChartData) error {
+	for i, x := range data.XValues {
+		entry[Time] = x.String()
+		entry[Rate] = fmt.Sprintf("%v", data.YValues[i])
+		entry[csvRTT], entry[csvCwnd], entry[csvRetrans] = "", "", ""
+		if i < len(data.TCPInfo) {
+			ti := data.TCPInfo[i]
+			entry[csvRTT] = ti.RTT.String()
+			entry[csvCwnd] = fmt.Sprintf("%d", ti.Cwnd)
+			entry[csvRetrans] = fmt.Sprintf("%d", ti.Retransmits)
+		}
+		if err := w.Write(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package goben
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/pflag"
+)
+
+// envPrefix prefixes the environment variable of every flag.
+const envPrefix = "GOBEN_"
+
+// EnvName returns the environment variable for a flag:
+// tcpReadSize is GOBEN_TCP_READ_SIZE.
+func EnvName(flag string) string {
+	var b strings.Builder
+	b.WriteString(envPrefix)
+	for i, r := range flag {
+		if i > 0 && unicode.IsUpper(r) && !unicode.IsUpper(rune(flag[i-1])) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// LoadEnv sets flags from their GOBEN_* environment variables, skipping
+// flags set on the command line, and reports all malformed values.
+// Call it before LoadConfigFile, so that the environment takes
+// precedence over the config file.
+func LoadEnv(flagset *pflag.FlagSet) error {
+	var errs []error
+	flagset.VisitAll(func(f *pflag.Flag) {
+		name := EnvName(f.Name)
+		value, found := os.LookupEnv(name)
+		if !found || f.Changed {
+			return
+		}
+		if err := flagset.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("env %s=%q: %w", name, value, err))
+		}
+	})
+	return errors.Join(errs...)
+}
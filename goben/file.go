@@ -0,0 +1,117 @@
+package goben
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// diskStats measures time spent in disk I/O during a file transfer.
+type diskStats struct {
+	bytes   int64
+	elapsed time.Duration
+}
+
+func (d diskStats) mbps() float64 {
+	if d.elapsed <= 0 {
+		return 0
+	}
+	return float64(8*d.bytes) / (1000000 * d.elapsed.Seconds())
+}
+
+// reportDisk reports the disk-bound throughput of the file transfer of a connection,
+// along with its network average. op is "read" or "write".
+func reportDisk(ctx context.Context, label, connIndex, op string, d diskStats) {
+	debugf(ctx, "%s: %s disk %s: %d bytes in %v", label, connIndex, op, d.bytes, d.elapsed)
+	hooksFrom(ctx).report(Report{Time: time.Now(), Conn: connIndex, Kind: "disk", Label: label, Mbps: d.mbps(), CpsLabel: op})
+}
+
+// fileSendCall reads the next chunk from f and sends it with write,
+// in place of the payload buffer.
+func fileSendCall(write call, f io.Reader, d *diskStats) call {
+	return func(p []byte) (int, error) {
+		begin := time.Now()
+		n, errRead := f.Read(p)
+		d.elapsed += time.Since(begin)
+		d.bytes += int64(n)
+		if n == 0 {
+			if errRead == io.EOF {
+				return 0, errLimitReached // file fully sent
+			}
+			return 0, errRead
+		}
+		return write(p[:n])
+	}
+}
+
+// fileRecvCall writes received data to f.
+func fileRecvCall(read call, f io.Writer, d *diskStats) call {
+	return func(p []byte) (int, error) {
+		n, errCall := read(p)
+		if n > 0 {
+			begin := time.Now()
+			written, errWrite := f.Write(p[:n])
+			d.elapsed += time.Since(begin)
+			d.bytes += int64(written)
+			if errWrite != nil && errCall == nil {
+				return n, fmt.Errorf("disk write: %w", errWrite)
+			}
+		}
+		return n, errCall
+	}
+}
+
+// openReceiveFile opens the destination for data received on connection c.
+// writeDir is either a directory, receiving one file per connection,
+// or an existing file such as /dev/null.
+func openReceiveFile(writeDir string, c int, remote string) (*os.File, error) {
+	info, errStat := os.Stat(writeDir)
+	if errStat != nil {
+		return nil, errStat
+	}
+	if !info.IsDir() {
+		return os.OpenFile(writeDir, os.O_WRONLY, 0)
+	}
+	name := fmt.Sprintf("goben-%d-%s.dat", c, remote)
+	return os.Create(filepath.Join(writeDir, name))
+}
+
+// updateSendFile bounds the test by the size of --sendFile.
+func updateSendFile(app *Config) error {
+	if app.SendFile == "" {
+		return nil
+	}
+	info, errStat := os.Stat(app.SendFile)
+	if errStat != nil {
+		return fmt.Errorf("bad sendFile: %w", errStat)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("bad sendFile: %s: not a regular file", app.SendFile)
+	}
+	size := info.Size()
+	switch {
+	case app.UDP:
+		return fmt.Errorf("bad sendFile: %s: requires TCP", app.SendFile)
+	case app.Opt.Verify:
+		return fmt.Errorf("bad sendFile: %s: cannot be combined with --verify", app.SendFile)
+	case app.LimitTotal, app.Opt.Blocks > 0, app.Opt.Bytes > 0 && app.Opt.Bytes != size:
+		return fmt.Errorf("bad sendFile: %s: the file size sets the transfer limit, do not use --bytes, --blocks or --limitTotal", app.SendFile)
+	case size == 0:
+		return fmt.Errorf("bad sendFile: %s: empty file", app.SendFile)
+	}
+	app.Opt.Bytes = size
+	return nil
+}
+
+func validateWriteDir(writeDir string) error {
+	if writeDir == "" {
+		return nil
+	}
+	if _, errStat := os.Stat(writeDir); errStat != nil {
+		return fmt.Errorf("bad writeDir: %w", errStat)
+	}
+	return nil
+}
@@ -1,7 +1,27 @@
 package goben
 
 import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
 )
 
 func TestAppendPort(t *testing.T) {
@@ -47,3 +67,1487 @@ func TestDefaultTimeUnit(t *testing.T) {
 		t.Errorf("implicit time unit should default to seconds")
 	}
 }
+
+func TestTCPInfoSampler(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("TCP_INFO is only supported on linux")
+	}
+
+	listener, errListen := net.Listen("tcp", "127.0.0.1:0")
+	if errListen != nil {
+		t.Fatalf("listen: %v", errListen)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, errAccept := listener.Accept()
+		if errAccept == nil {
+			io.Copy(io.Discard, conn)
+			conn.Close()
+		}
+	}()
+
+	conn, errDial := net.Dial("tcp", listener.Addr().String())
+	if errDial != nil {
+		t.Fatalf("dial: %v", errDial)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write(make([]byte, 100000)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	sample := tcpInfoSampler(conn)
+	if sample == nil {
+		t.Fatalf("expected TCP_INFO sampler for TCP connection")
+	}
+	info, ok := sample()
+	if !ok {
+		t.Fatalf("TCP_INFO sample failed")
+	}
+	if info.Cwnd == 0 {
+		t.Errorf("expected non-zero congestion window: %+v", info)
+	}
+}
+
+func TestUpdateTOS(t *testing.T) {
+	app := Config{DSCP: 46}
+	if err := updateTOS(&app); err != nil || app.Opt.TOS != 184 {
+		t.Errorf("dscp 46: tos=%d err=%v wanted tos=184", app.Opt.TOS, err)
+	}
+
+	app = Config{DSCP: 46}
+	app.Opt.TOS = 4
+	if err := updateTOS(&app); err == nil {
+		t.Errorf("conflicting dscp and tos should fail")
+	}
+
+	app = Config{DSCP: 64}
+	if err := updateTOS(&app); err == nil {
+		t.Errorf("dscp out of range should fail")
+	}
+
+	app = Config{}
+	app.Opt.TOS = 256
+	if err := updateTOS(&app); err == nil {
+		t.Errorf("tos out of range should fail")
+	}
+}
+
+func TestParseHosts(t *testing.T) {
+	app := Config{
+		Hosts:       HostList{"127.0.0.1", "[::1]:9000@[::1]", "localhost:8000@127.0.0.1:2000"},
+		DefaultPort: ":8080",
+	}
+	targets, err := parseHosts(&app)
+	if err != nil {
+		t.Fatalf("parseHosts: %v", err)
+	}
+	if len(targets) != 3 {
+		t.Fatalf("parseHosts: got %d targets, wanted 3", len(targets))
+	}
+	expectTarget(t, targets[0], "127.0.0.1:8080", "")
+	expectTarget(t, targets[1], "[::1]:9000", "[::1]:0")
+	expectTarget(t, targets[2], "localhost:8000", "127.0.0.1:2000")
+
+	// global local address applies to hosts without their own
+	app.LocalAddr = "127.0.0.1"
+	targets, err = parseHosts(&app)
+	if err != nil {
+		t.Fatalf("parseHosts: %v", err)
+	}
+	expectTarget(t, targets[0], "127.0.0.1:8080", "127.0.0.1:0")
+	expectTarget(t, targets[2], "localhost:8000", "127.0.0.1:2000")
+
+	for _, bad := range []string{"127.0.0.1@", "127.0.0.1@bad:address:1", "127.0.0.1@127.0.0.1:badport"} {
+		app := Config{Hosts: HostList{bad}, DefaultPort: ":8080"}
+		if _, err := parseHosts(&app); err == nil {
+			t.Errorf("parseHosts: %q should fail", bad)
+		}
+	}
+
+	app = Config{Hosts: HostList{"127.0.0.1"}, DefaultPort: ":8080", LocalAddr: "bad:address:1"}
+	if _, err := parseHosts(&app); err == nil {
+		t.Errorf("parseHosts: bad global local address should fail")
+	}
+}
+
+func expectTarget(t *testing.T, target hostTarget, host, local string) {
+	t.Helper()
+	if target.host != host {
+		t.Errorf("target host=%s wanted=%s", target.host, host)
+	}
+	var gotLocal string
+	if target.localAddr != nil {
+		gotLocal = target.localAddr.String()
+	}
+	if gotLocal != local {
+		t.Errorf("target %s localAddr=%s wanted=%s", host, gotLocal, local)
+	}
+}
+
+func TestValidateBindDevice(t *testing.T) {
+	if err := validateBindDevice(""); err != nil {
+		t.Errorf("empty device should be accepted: %v", err)
+	}
+	if err := validateBindDevice("goben-no-such-device"); err == nil {
+		t.Errorf("missing device should fail")
+	}
+}
+
+func TestAccountOmit(t *testing.T) {
+	start := time.Now().Add(-2 * time.Second)
+	acc := newAccount(start, time.Second)
+	var stat ChartData
+
+	// warm-up has already elapsed: this update closes the omitted interval
+	acc.update(1000, time.Hour, "0/1", "test", "x/s", &stat, false)
+	if acc.omitting {
+		t.Errorf("account should stop omitting after warm-up")
+	}
+	if acc.avgSize != 1000 || acc.avgCalls != 1 {
+		t.Errorf("average base should skip warm-up: size=%d calls=%d", acc.avgSize, acc.avgCalls)
+	}
+	if len(stat.YValues) != 0 {
+		t.Errorf("omitted interval should not be recorded: %v", stat.YValues)
+	}
+
+	acc.update(2000, time.Hour, "0/1", "test", "x/s", &stat, true)
+	if len(stat.YValues) != 1 {
+		t.Errorf("measured interval should be recorded: %v", stat.YValues)
+	}
+
+	var agg aggregate
+	acc.average("0/1", "test", "x/s", &agg)
+	if agg.Bytes != 3000 {
+		t.Errorf("aggregate should count all bytes: %d", agg.Bytes)
+	}
+
+	// stopped within the warm-up period: no average, only bytes are counted
+	short := newAccount(time.Now(), time.Hour)
+	short.update(1000, time.Hour, "1/1", "test", "x/s", nil, true)
+	var aggShort aggregate
+	if mbps, cps := short.average("1/1", "test", "x/s", &aggShort); mbps != 0 || cps != 0 || aggShort.Mbps != 0 || aggShort.Cps != 0 || aggShort.Bytes != 1000 {
+		t.Errorf("average within warm-up: mbps=%v cps=%d aggregate mbps=%v cps=%d bytes=%d", mbps, cps, aggShort.Mbps, aggShort.Cps, aggShort.Bytes)
+	}
+}
+
+func TestParseOmit(t *testing.T) {
+	app := Config{Omit: "2s"}
+	app.Opt.TotalDuration = 10 * time.Second
+	if err := parseOmit(&app); err != nil || app.Opt.Omit != 2*time.Second {
+		t.Errorf("omit=2s: got %v err=%v", app.Opt.Omit, err)
+	}
+	app.Omit = "10s"
+	if err := parseOmit(&app); err == nil {
+		t.Errorf("omit as long as totalDuration should fail")
+	}
+	app.Omit = "-1s"
+	if err := parseOmit(&app); err == nil {
+		t.Errorf("negative omit should fail")
+	}
+}
+
+func TestValidateRampSchedule(t *testing.T) {
+	app := Config{rampInterval: 900 * time.Millisecond, targets: []hostTarget{{host: "h:8080", connections: 3}}}
+	app.Opt.SyncStart = true
+	app.Opt.TotalDuration = 3 * time.Second
+	if err := validateRampSchedule(&app); err != nil {
+		t.Errorf("last connection starts at 1.8s of 3s: %v", err)
+	}
+	app.Opt.Omit = 1500 * time.Millisecond
+	if err := validateRampSchedule(&app); err == nil {
+		t.Errorf("last connection would stop within its warm-up period")
+	}
+	app.rampInterval = 2 * time.Second
+	app.Opt.Omit = 0
+	if err := validateRampSchedule(&app); err == nil {
+		t.Errorf("last connection would start after totalDuration")
+	}
+}
+
+func TestStartBarrier(t *testing.T) {
+	const connections = 5
+	const tolerance = 20 * time.Millisecond
+
+	b := newStartBarrier()
+
+	var lastArrival time.Time
+	var mutex sync.Mutex
+	starts := make([]time.Time, connections)
+
+	var wg sync.WaitGroup
+	for i := range connections {
+		b.add()
+		wg.Go(func() {
+			// connections become ready at different times
+			time.Sleep(time.Duration(i) * 10 * time.Millisecond)
+			mutex.Lock()
+			lastArrival = time.Now()
+			mutex.Unlock()
+			b.arrive()
+			if _, ok := b.wait(context.Background()); !ok {
+				t.Errorf("connection %d: wait failed", i)
+			}
+			starts[i] = time.Now()
+		})
+	}
+
+	b.arrive() // dialer done
+
+	wg.Wait()
+
+	for i, s := range starts {
+		if s.Before(lastArrival) {
+			t.Errorf("connection %d started before the last arrival", i)
+		}
+		if d := s.Sub(starts[0]).Abs(); d > tolerance {
+			t.Errorf("connection %d started %v apart from connection 0 (tolerance %v)", i, d, tolerance)
+		}
+	}
+}
+
+func TestStartBarrierCancel(t *testing.T) {
+	b := newStartBarrier()
+	b.add()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := b.wait(ctx); ok {
+		t.Errorf("wait should fail on cancelled context")
+	}
+}
+
+func TestLimitCall(t *testing.T) {
+	var total int
+	sink := func(p []byte) (int, error) {
+		total += len(p)
+		return len(p), nil
+	}
+
+	f := limitCall(sink, 2500)
+	buf := make([]byte, 1000)
+	for range 3 {
+		if _, err := f(buf); err != nil {
+			t.Fatalf("unexpected error before limit: %v", err)
+		}
+	}
+	if total != 2500 {
+		t.Errorf("limitCall: transferred %d bytes, wanted 2500", total)
+	}
+	if _, err := f(buf); !errors.Is(err, errLimitReached) {
+		t.Errorf("limitCall: expected errLimitReached, got %v", err)
+	}
+}
+
+func TestSplitLimit(t *testing.T) {
+	var sum int64
+	for seq := range 3 {
+		sum += splitLimit(10, seq, 3)
+	}
+	if sum != 10 {
+		t.Errorf("splitLimit: shares add up to %d, wanted 10", sum)
+	}
+	if splitLimit(10, 0, 3) != 4 || splitLimit(10, 2, 3) != 3 {
+		t.Errorf("splitLimit: remainder should go to the first connections")
+	}
+	if splitLimit(0, 0, 3) != 0 {
+		t.Errorf("splitLimit: unlimited should stay unlimited")
+	}
+}
+
+func TestByteLimit(t *testing.T) {
+	opt := Options{TCPWriteSize: 1000, UDPWriteSize: 100, Blocks: 5}
+	if got := opt.byteLimit(false); got != 5000 {
+		t.Errorf("byteLimit TCP blocks: %d wanted 5000", got)
+	}
+	if got := opt.byteLimit(true); got != 500 {
+		t.Errorf("byteLimit UDP blocks: %d wanted 500", got)
+	}
+	opt = Options{Bytes: 1234}
+	if got := opt.byteLimit(false); got != 1234 {
+		t.Errorf("byteLimit bytes: %d wanted 1234", got)
+	}
+}
+
+func TestVerifierStream(t *testing.T) {
+	sender := newVerifier(42, streamUpload, false)
+	receiver := newVerifier(42, streamUpload, false)
+
+	var wire []byte
+	for _, size := range []int{1000, 7, 4096} {
+		p := make([]byte, size)
+		sender.fill(p)
+		wire = append(wire, p...)
+	}
+
+	wire[100] ^= 0xff // corrupt one byte
+
+	// receive in chunks unrelated to the writes
+	for len(wire) > 0 {
+		n := min(len(wire), 333)
+		receiver.check(wire[:n])
+		wire = wire[n:]
+	}
+
+	if receiver.result.Bytes != 5103 {
+		t.Errorf("verified %d bytes, wanted 5103", receiver.result.Bytes)
+	}
+	if receiver.result.CorruptBytes != 1 {
+		t.Errorf("corrupt bytes=%d wanted 1", receiver.result.CorruptBytes)
+	}
+}
+
+func TestVerifierDatagrams(t *testing.T) {
+	sender := newVerifier(7, streamDownload, true)
+	receiver := newVerifier(7, streamDownload, true)
+
+	datagrams := make([][]byte, 4)
+	for i := range datagrams {
+		datagrams[i] = make([]byte, 512)
+		sender.fill(datagrams[i])
+	}
+
+	datagrams[2][300] ^= 0x01 // corrupt one datagram
+
+	// datagram 1 is lost, the others arrive reordered
+	for _, i := range []int{3, 0, 2} {
+		receiver.check(datagrams[i])
+	}
+	receiver.check([]byte{1, 2, 3}) // truncated
+
+	if receiver.result.Datagrams != 4 {
+		t.Errorf("verified %d datagrams, wanted 4", receiver.result.Datagrams)
+	}
+	if receiver.result.CorruptDatagrams != 2 {
+		t.Errorf("corrupt datagrams=%d wanted 2", receiver.result.CorruptDatagrams)
+	}
+
+	// a different seed must not verify
+	other := newVerifier(8, streamDownload, true)
+	other.check(datagrams[0])
+	if other.result.CorruptDatagrams != 1 {
+		t.Errorf("datagram from another seed should be corrupt")
+	}
+}
+
+func TestParsePayload(t *testing.T) {
+	for spec, wanted := range map[string]string{
+		"":                  "random",
+		"random":            "random",
+		"ZEROS":             "zeros",
+		"text":              "text",
+		"compressible:0.50": "compressible:0.5",
+	} {
+		got, _, err := parsePayload(spec, 1000)
+		if err != nil || got != wanted {
+			t.Errorf("parsePayload(%q)=%q err=%v wanted %q", spec, got, err, wanted)
+		}
+	}
+
+	for _, bad := range []string{"bogus", "compressible", "compressible:1.5", "file:/no/such/file"} {
+		if _, _, err := parsePayload(bad, 1000); err == nil {
+			t.Errorf("parsePayload(%q) should fail", bad)
+		}
+	}
+
+	_, data, err := parsePayload("file:goben_test.go", 10)
+	if err != nil || len(data) != 10 {
+		t.Errorf("file payload should be capped: len=%d err=%v", len(data), err)
+	}
+}
+
+func TestPayloadValidateTwice(t *testing.T) {
+	app := NewDefaultConfig()
+	app.Hosts = HostList{"localhost"}
+	app.Opt.Payload = "file:goben_test.go"
+
+	// repeat and scenario runs validate copies of an already validated config
+	for i := range 2 {
+		if err := ValidateAndUpdateConfig(app); err != nil {
+			t.Fatalf("validation %d: %v", i+1, err)
+		}
+		if app.Opt.Payload != "file:goben_test.go" || app.payload != payloadFile || len(app.Opt.PayloadData) == 0 {
+			t.Errorf("validation %d: payload=%q normalized=%q data=%d bytes", i+1, app.Opt.Payload, app.payload, len(app.Opt.PayloadData))
+		}
+	}
+}
+
+func TestPayloadCompressibility(t *testing.T) {
+	const size = 100000
+
+	compressed := func(spec string) float64 {
+		var out bytes.Buffer
+		w, _ := flate.NewWriter(&out, flate.BestSpeed)
+		w.Write(payloadBuf(Options{Payload: spec}, size))
+		w.Close()
+		return float64(out.Len()) / size
+	}
+
+	if r := compressed("random"); r < 0.95 {
+		t.Errorf("random payload should be incompressible: ratio=%f", r)
+	}
+	if r := compressed("zeros"); r > 0.05 {
+		t.Errorf("zeros payload should be compressible: ratio=%f", r)
+	}
+	if r := compressed("compressible:0.75"); r < 0.2 || r > 0.35 {
+		t.Errorf("compressible:0.75 payload should compress to about 25%%: ratio=%f", r)
+	}
+}
+
+func TestFileCalls(t *testing.T) {
+	data := bytes.Repeat([]byte("goben file "), 1000)
+
+	var wire bytes.Buffer
+	var readDisk diskStats
+	send := fileSendCall(wire.Write, bytes.NewReader(data), &readDisk)
+
+	buf := make([]byte, 4096)
+	for {
+		_, err := send(buf)
+		if errors.Is(err, errLimitReached) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	if readDisk.bytes != int64(len(data)) {
+		t.Errorf("disk read: expected=%d got=%d", len(data), readDisk.bytes)
+	}
+
+	var out bytes.Buffer
+	var writeDisk diskStats
+	recv := fileRecvCall(wire.Read, &out, &writeDisk)
+	for {
+		if _, err := recv(buf); err != nil {
+			break
+		}
+	}
+	if !bytes.Equal(data, out.Bytes()) {
+		t.Errorf("received file differs from sent file")
+	}
+	if writeDisk.bytes != int64(len(data)) {
+		t.Errorf("disk write: expected=%d got=%d", len(data), writeDisk.bytes)
+	}
+
+	// parallel connections add up, as network rates do
+	var agg aggregate
+	agg.addDisk(diskStats{bytes: 1000000, elapsed: time.Second})
+	agg.addDisk(diskStats{bytes: 1000000, elapsed: time.Second})
+	if agg.DiskMbps != 16 {
+		t.Errorf("aggregate disk rate: expected=16 got=%v", agg.DiskMbps)
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	var out bytes.Buffer
+	var reports []Report
+	h := newRunHooks(slog.New(newClassicHandler(log.New(&out, "", 0), slog.LevelInfo)),
+		ReportFunc(func(r Report) { reports = append(reports, r) }))
+
+	ctx := withHooks(context.Background(), h)
+	infof(ctx, "hello %d", 1)
+	if out.String() != "hello 1\n" {
+		t.Errorf("unexpected log: %q", out.String())
+	}
+
+	acc := newAccount(time.Now(), 0)
+	acc.hooks = hooksFrom(ctx)
+	acc.update(1000, time.Hour, "0/1", "clientReader", "rcv/s", nil, true)
+	acc.average("0/1", "clientReader", "rcv/s", &aggregate{})
+
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(reports))
+	}
+	if reports[0].Kind != "report" || reports[1].Kind != "average" {
+		t.Errorf("unexpected report kinds: %q %q", reports[0].Kind, reports[1].Kind)
+	}
+	if reports[0].Conn != "0/1" || reports[0].Label != "clientReader" {
+		t.Errorf("unexpected report: %+v", reports[0])
+	}
+
+	// without a reporter, reports are logged by one LogReporter built with the hooks
+	out.Reset()
+	logged := newRunHooks(h.logger, nil)
+	if logged.getReporter() != logged.getReporter() {
+		t.Errorf("default reporter should be built once")
+	}
+	logged.report(Report{Conn: "0/1", Kind: "report", Label: "clientReader"})
+	if !strings.Contains(out.String(), "clientReader") {
+		t.Errorf("default reporter should log to the hooks logger: %q", out.String())
+	}
+}
+
+func TestReporters(t *testing.T) {
+	if _, err := newReporter("bogus", nil, io.Discard, ""); err == nil {
+		t.Errorf("unexpected success for bogus reporter")
+	}
+
+	r := Report{Conn: "0/1", Kind: "report", Label: "clientReader", Mbps: 12.5, Cps: 3, CpsLabel: "rcv/s"}
+
+	var logOut bytes.Buffer
+	NewLogReporter(slog.New(newClassicHandler(log.New(&logOut, "", 0), slog.LevelInfo))).Report(r)
+	if got, expected := logOut.String(), "0/1  report   clientReader rate: 12.500000 Mbps      3 rcv/s\n"; got != expected {
+		t.Errorf("log reporter: expected=%q got=%q", expected, got)
+	}
+
+	// retransmits are reported only when sampled from TCP_INFO
+	for _, sampled := range []bool{false, true} {
+		logOut.Reset()
+		NewLogReporter(slog.New(newClassicHandler(log.New(&logOut, "", 0), slog.LevelInfo))).Summary(ClientStats{RetransmitsSampled: sampled})
+		if got := strings.Contains(logOut.String(), "aggregate retransmits"); got != sampled {
+			t.Errorf("log reporter summary: sampled=%v retransmits line=%v: %q", sampled, got, logOut.String())
+		}
+	}
+
+	var jsonOut bytes.Buffer
+	j := NewJSONReporter(&jsonOut)
+	j.Report(r)
+	j.Summary(ClientStats{ReadMbps: 42})
+
+	dec := json.NewDecoder(&jsonOut)
+	var event struct {
+		Event    string
+		Mbps     float64
+		ReadMbps float64
+	}
+	if err := dec.Decode(&event); err != nil || event.Event != "report" || event.Mbps != 12.5 {
+		t.Errorf("json report: %+v: %v", event, err)
+	}
+	if err := dec.Decode(&event); err != nil || event.Event != "summary" || event.ReadMbps != 42 {
+		t.Errorf("json summary: %+v: %v", event, err)
+	}
+
+	var tableOut bytes.Buffer
+	NewTableReporter(&tableOut).Report(r)
+	if lines := strings.Count(tableOut.String(), "\n"); lines != 2 {
+		t.Errorf("table reporter: expected header and one row, got %d lines: %q", lines, tableOut.String())
+	}
+}
+
+func TestLogLevels(t *testing.T) {
+	var out bytes.Buffer
+	logger, errLogger := NewLogger(&out, "classic", slog.LevelWarn)
+	if errLogger != nil {
+		t.Fatalf("NewLogger: %v", errLogger)
+	}
+	ctx := withHooks(context.Background(), newRunHooks(logger, nil))
+
+	debugf(ctx, "debug")
+	infof(ctx, "info")
+	warnf(ctx, "warn")
+	summaryf(ctx, "summary")
+
+	got := out.String()
+	if strings.Contains(got, "debug") || strings.Contains(got, "info") {
+		t.Errorf("quiet log should skip debug and info: %q", got)
+	}
+	if !strings.Contains(got, "WARN warn\n") || !strings.Contains(got, " summary\n") {
+		t.Errorf("quiet log should keep warnings and results: %q", got)
+	}
+
+	out.Reset()
+	jsonLogger, _ := NewLogger(&out, "json", slog.LevelInfo)
+	jsonLogger.Log(context.Background(), LevelSummary, "result")
+	var record struct {
+		Level string `json:"level"`
+		Msg   string `json:"msg"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil || record.Level != "SUMMARY" || record.Msg != "result" {
+		t.Errorf("json log: %+v: %v", record, err)
+	}
+
+	if _, err := NewLogger(&out, "bogus", slog.LevelInfo); err == nil {
+		t.Errorf("unexpected success for bogus log format")
+	}
+}
+
+func TestSummaryTable(t *testing.T) {
+	conn := func(index int, mbps float64, errs ...string) ConnStats {
+		return ConnStats{
+			Index:  index,
+			Proto:  "TCP",
+			Read:   DirStats{Mbps: mbps, Bytes: 2000000, Intervals: ChartData{YValues: []float64{mbps - 1, mbps + 1}}},
+			Write:  DirStats{Mbps: mbps, Bytes: 1500},
+			Errors: errs,
+		}
+	}
+	s := ClientStats{Hosts: []HostStats{
+		{Host: "a:8080", Connections: []ConnStats{conn(0, 10), conn(1, 20, "reset")}, ReadMbps: 30, ReadBytes: 4000000, WriteBytes: 3000},
+		{Host: "b:8080", Errors: []string{"refused"}},
+	}}
+
+	var out bytes.Buffer
+	if err := WriteSummaryTable(&out, s, ""); err != nil {
+		t.Fatalf("WriteSummaryTable: %v", err)
+	}
+	table := out.String()
+
+	for _, expected := range []string{
+		"HOST", "20.00 (19.00-21.00)", "1.50 kB", "2.00 MB",
+		"a:8080  total", "4.00 MB", "TOTAL", "a:8080 1: reset", "b:8080: refused",
+	} {
+		if !strings.Contains(table, expected) {
+			t.Errorf("summary table missing %q:\n%s", expected, table)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for spec, wanted := range map[string]int64{
+		"1000000": 1000000,
+		"64K":     64000,
+		"64kb":    64000,
+		"64KiB":   65536,
+		"1M":      1000000,
+		"1MiB":    1 << 20,
+		"1.5G":    1500000000,
+		"2 gi":    2 << 30,
+	} {
+		got, err := parseSize(spec)
+		if err != nil || got != wanted {
+			t.Errorf("parseSize(%q)=%d err=%v wanted %d", spec, got, err, wanted)
+		}
+	}
+
+	for _, bad := range []string{"", "K", "1X", "1.5", "0.1Ki", "1Kbps"} {
+		if _, err := parseSize(bad); err == nil {
+			t.Errorf("parseSize(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	for spec, wanted := range map[string]float64{
+		"500":     500,
+		"500M":    500,
+		"2.5G":    2500,
+		"2.5Gbps": 2500,
+		"100Kbps": 0.1,
+		"1000bps": 0.001,
+		"10MB/s":  80,
+		"10MBps":  80,
+		"1 mbps":  1,
+	} {
+		got, err := parseRate(spec)
+		if err != nil || got != wanted {
+			t.Errorf("parseRate(%q)=%v err=%v wanted %v", spec, got, err, wanted)
+		}
+	}
+
+	for _, bad := range []string{"", "fast", "1X", "1Mbit"} {
+		if _, err := parseRate(bad); err == nil {
+			t.Errorf("parseRate(%q) should fail", bad)
+		}
+	}
+}
+
+func TestRateUnit(t *testing.T) {
+	auto, _ := lookupRateUnit("AUTO")
+	gbps, _ := lookupRateUnit("gbps")
+	bytes, _ := lookupRateUnit("bytes/s")
+	for _, c := range []struct {
+		mbps   float64
+		unit   rateUnit
+		wanted string
+	}{
+		{941.5, auto, "941.50 Mbps"},
+		{12500, auto, "12.50 Gbps"},
+		{0.25, auto, "250.00 Kbps"},
+		{0, auto, "0.00 Mbps"},
+		{500, gbps, "0.50 Gbps"},
+		{8, bytes, "1000000.00 bytes/s"},
+	} {
+		if got := formatRate(c.mbps, c.unit); got != c.wanted {
+			t.Errorf("formatRate(%v, %s)=%q wanted %q", c.mbps, c.unit.name, got, c.wanted)
+		}
+	}
+
+	if _, err := lookupRateUnit("furlongs"); err == nil {
+		t.Errorf("unknown unit should fail")
+	}
+
+	info := newExportInfo(ChartData{YValues: []float64{500}}, ChartData{YValues: []float64{2000}}, auto)
+	if info.Unit != "Gbps" || info.Input.YValues[0] != 0.5 || info.Output.YValues[0] != 2 {
+		t.Errorf("export should be scaled to one unit: %+v", info)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	app := Config{}
+	flagset := pflag.NewFlagSet("", pflag.ContinueOnError)
+	app.AssignFlags(flagset)
+	if err := flagset.Parse([]string{"--connections", "4"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	data := []byte(`
+connections: 2
+maxSpeed: 1G
+tcpReadSize: 64K
+export: [csv, png]
+hosts:
+  - 10.0.0.1
+  - host: 10.0.0.2:9000
+    localAddr: 127.0.0.1
+    connections: 8
+    maxSpeed: 500M
+`)
+	if err := app.loadConfig(flagset, data); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	if app.Connections != 4 {
+		t.Errorf("flag should take precedence: connections=%d", app.Connections)
+	}
+	if app.Opt.MaxSpeed != 1000 || app.Opt.TCPReadSize != 64000 {
+		t.Errorf("config values not loaded: maxSpeed=%v tcpReadSize=%d", app.Opt.MaxSpeed, app.Opt.TCPReadSize)
+	}
+	if strings.Join(app.Export, ",") != "csv,png" {
+		t.Errorf("export: %q", app.Export)
+	}
+
+	targets, err := parseHosts(&app)
+	if err != nil || len(targets) != 2 {
+		t.Fatalf("parseHosts: %v %v", targets, err)
+	}
+	if targets[0].connections != 4 || targets[0].maxSpeed != 1000 {
+		t.Errorf("host without override: %+v", targets[0])
+	}
+	if targets[1].host != "10.0.0.2:9000" || targets[1].localAddr == nil || targets[1].connections != 8 || targets[1].maxSpeed != 500 {
+		t.Errorf("host with override: %+v", targets[1])
+	}
+
+	// the dump loads back to the same options
+	var dump bytes.Buffer
+	if err := app.WriteConfig(&dump, flagset); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+	app2 := Config{}
+	flagset2 := pflag.NewFlagSet("", pflag.ContinueOnError)
+	app2.AssignFlags(flagset2)
+	if err := app2.loadConfig(flagset2, dump.Bytes()); err != nil {
+		t.Fatalf("loadConfig dump: %v\n%s", err, dump.String())
+	}
+	if app2.Connections != 4 || app2.Opt.MaxSpeed != 1000 || app2.HostOverrides["10.0.0.2:9000@127.0.0.1"] != app.HostOverrides["10.0.0.2:9000@127.0.0.1"] {
+		t.Errorf("dump should reproduce the options:\n%s", dump.String())
+	}
+
+	for _, bad := range []string{"bogus: 1", "connections: [1, 2]", "maxSpeed: fast", "hosts: [{connections: 2}]", "hosts: [{host: a, bogus: 1}]", "hosts: [a, {host: a, connections: 2}]", "- a"} {
+		app := Config{}
+		flagset := pflag.NewFlagSet("", pflag.ContinueOnError)
+		app.AssignFlags(flagset)
+		if err := app.loadConfig(flagset, []byte(bad)); err == nil {
+			t.Errorf("loadConfig(%q) should fail", bad)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	for flag, wanted := range map[string]string{
+		"tcpReadSize":   "GOBEN_TCP_READ_SIZE",
+		"tls":           "GOBEN_TLS",
+		"tlsAuthClient": "GOBEN_TLS_AUTH_CLIENT",
+		"maxSpeed":      "GOBEN_MAX_SPEED",
+	} {
+		if got := EnvName(flag); got != wanted {
+			t.Errorf("EnvName(%q)=%q wanted %q", flag, got, wanted)
+		}
+	}
+
+	t.Setenv("GOBEN_CONNECTIONS", "3")
+	t.Setenv("GOBEN_MAX_SPEED", "2G")
+	t.Setenv("GOBEN_REPORT_INTERVAL", "5s")
+	t.Setenv("GOBEN_HOSTS", "a,b")
+
+	app := Config{}
+	flagset := pflag.NewFlagSet("", pflag.ContinueOnError)
+	app.AssignFlags(flagset)
+	if err := flagset.Parse([]string{"--reportInterval", "1s"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if err := LoadEnv(flagset); err != nil {
+		t.Fatalf("LoadEnv: %v", err)
+	}
+	if err := app.loadConfig(flagset, []byte("connections: 7\nudp: true\n")); err != nil {
+		t.Fatalf("loadConfig: %v", err)
+	}
+
+	if app.ReportInterval != "1s" {
+		t.Errorf("flag should take precedence over env: %q", app.ReportInterval)
+	}
+	if app.Connections != 3 || app.Opt.MaxSpeed != 2000 || app.Hosts.String() != "a,b" {
+		t.Errorf("env should take precedence over config: connections=%d maxSpeed=%v hosts=%q", app.Connections, app.Opt.MaxSpeed, app.Hosts)
+	}
+	if !app.UDP {
+		t.Errorf("config should apply when not in env")
+	}
+
+	t.Setenv("GOBEN_TOS", "x")
+	t.Setenv("GOBEN_BYTES", "1.5")
+	flagset = pflag.NewFlagSet("", pflag.ContinueOnError)
+	(&Config{}).AssignFlags(flagset)
+	err := LoadEnv(flagset)
+	if err == nil || !strings.Contains(err.Error(), "GOBEN_TOS") || !strings.Contains(err.Error(), "GOBEN_BYTES") {
+		t.Errorf("malformed values should be reported: %v", err)
+	}
+}
+
+func TestScenarios(t *testing.T) {
+	var out bytes.Buffer
+	app := Config{output: &out}
+	flagset := pflag.NewFlagSet("", pflag.ContinueOnError)
+	app.AssignFlags(flagset)
+	if err := flagset.Parse([]string{"--hosts", "10.0.0.1", "--connections", "4", "--maxSpeed", "1G", "--reporter", "table"}); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	data := []byte(`
+scenarios:
+  - name: tcp-1
+    connections: 1
+  - udp: true
+    export: [csv, out/x-%d-%s.png]
+matrix:
+  direction: [both, receive]
+`)
+	scenarios, err := parseScenarios(data, flagset)
+	if err != nil {
+		t.Fatalf("parseScenarios: %v", err)
+	}
+	var names []string
+	for _, s := range scenarios {
+		names = append(names, s.Name)
+	}
+	if got, wanted := strings.Join(names, "|"), "tcp-1 direction=both|tcp-1 direction=receive|scenario-2 direction=both|scenario-2 direction=receive"; got != wanted {
+		t.Errorf("scenario names: %q wanted %q", got, wanted)
+	}
+
+	first, err := app.scenarioConfig(flagset, scenarios[1])
+	if err != nil {
+		t.Fatalf("scenarioConfig: %v", err)
+	}
+	if first.Connections != 1 || first.Opt.MaxSpeed != 1000 || first.Hosts.String() != "10.0.0.1" || !first.PassiveClient {
+		t.Errorf("scenario should override the base options: %+v", first)
+	}
+	if got := first.exportFilename("result-%d-%s.csv"); got != "tcp-1_direction_receive-result-%d-%s.csv" {
+		t.Errorf("export filename: %q", got)
+	}
+	if err := updateReporter(first); err != nil {
+		t.Fatalf("updateReporter: %v", err)
+	}
+	first.reporter.Report(Report{Conn: "0/1", Kind: "report", Label: "clientReader", Mbps: 1})
+	if out.Len() == 0 {
+		t.Errorf("scenario reporter should write to the base config output")
+	}
+
+	second, err := app.scenarioConfig(flagset, scenarios[2])
+	if err != nil {
+		t.Fatalf("scenarioConfig: %v", err)
+	}
+	if second.Connections != 4 || !second.UDP || second.PassiveClient || strings.Join(second.Export, ",") != "csv,out/x-%d-%s.png" {
+		t.Errorf("scenario options: %+v", second)
+	}
+	if got := second.exportFilename("out/x-%d-%s.png"); got != "out/scenario-2_direction_both-x-%d-%s.png" {
+		t.Errorf("export filename: %q", got)
+	}
+	if app.Connections != 4 || app.UDP {
+		t.Errorf("scenarios should not change the base config: %+v", app)
+	}
+
+	for _, bad := range []string{"", "scenarios: [{bogus: 1}]", "scenarios: [{hosts: a}]", "matrix: {repeat: [1, 3]}", "matrix: {udp: true}", "scenarios: [{direction: up}]"} {
+		scenarios, err := parseScenarios([]byte(bad), flagset)
+		if err == nil {
+			_, err = app.scenarioConfig(flagset, scenarios[0])
+		}
+		if err == nil {
+			t.Errorf("scenarios %q should fail", bad)
+		}
+	}
+
+	var table bytes.Buffer
+	results := []ScenarioResult{
+		{Name: "a", Stats: ClientStats{ReadMbps: 1500, WriteMbps: 2500, Hosts: []HostStats{{Connections: make([]ConnStats, 2)}}}},
+		{Name: "b", Err: errors.New("open: no successful connections")},
+	}
+	if err := WriteScenarioTable(&table, results, "auto"); err != nil {
+		t.Fatalf("WriteScenarioTable: %v", err)
+	}
+	for _, expected := range []string{"SEND Gbps", "2.50", "1.50", "open: no successful connections"} {
+		if !strings.Contains(table.String(), expected) {
+			t.Errorf("scenario table missing %q:\n%s", expected, table.String())
+		}
+	}
+
+	// a bad unit fails before running any scenario
+	app.Scenarios = filepath.Join(t.TempDir(), "scenarios.yaml")
+	if err := os.WriteFile(app.Scenarios, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	app.Unit = "furlongs"
+	if results, err := RunScenarios(context.Background(), &app, flagset); err == nil || len(results) != 0 {
+		t.Errorf("bad unit should fail before running scenarios: %d results, err=%v", len(results), err)
+	}
+	if err := WriteScenarioTable(io.Discard, results, "furlongs"); err == nil {
+		t.Errorf("WriteScenarioTable should fail with a bad unit")
+	}
+}
+
+func TestRunStats(t *testing.T) {
+	s := newRunStats([]float64{4, 1, 3, 2})
+	if s.Mean != 2.5 || s.Median != 2.5 || s.Min != 1 || s.Max != 4 {
+		t.Errorf("stats: %+v", s)
+	}
+	// sample stddev of 1..4 is sqrt(5/3), t(3) = 3.182
+	if math.Abs(s.StdDev-1.2910) > 0.0001 || math.Abs(s.CIHigh-s.Mean-3.182*s.StdDev/2) > 1e-9 || math.Abs((s.Mean-s.CILow)-(s.CIHigh-s.Mean)) > 1e-9 {
+		t.Errorf("stddev/ci: %+v", s)
+	}
+
+	one := newRunStats([]float64{7})
+	if one.Median != 7 || one.StdDev != 0 || one.CILow != 7 || one.CIHigh != 7 {
+		t.Errorf("single run: %+v", one)
+	}
+
+	auto, _ := lookupRateUnit("auto")
+	rs := newRepeatStats([]ClientStats{{ReadMbps: 1000, WriteMbps: 2000}, {ReadMbps: 3000, WriteMbps: 4000}}, []string{"run 3: refused"}, auto)
+	if rs.Unit != "Gbps" || rs.ReadRate.Mean != 2 || rs.WriteRate.Values[1] != 4 {
+		t.Errorf("repeat stats: %+v", rs)
+	}
+
+	var table bytes.Buffer
+	if err := WriteRepeatTable(&table, rs); err != nil {
+		t.Fatalf("WriteRepeatTable: %v", err)
+	}
+	for _, expected := range []string{"REPEAT Gbps", "recv", "2.00", "failed run 3: refused"} {
+		if !strings.Contains(table.String(), expected) {
+			t.Errorf("repeat table missing %q:\n%s", expected, table.String())
+		}
+	}
+}
+
+func TestCompareExports(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	chart := func(values ...float64) ChartData {
+		var data ChartData
+		for i, v := range values {
+			data.XValues = append(data.XValues, now.Add(time.Duration(i)*time.Second))
+			data.YValues = append(data.YValues, v)
+		}
+		return data
+	}
+
+	gbps, _ := lookupRateUnit("Gbps")
+	baseline := newExportInfo(chart(1000, 900, 1100), chart(500, 500, 500), gbps)
+	current := newExportInfo(chart(1000, 950, 1050), chart(400, 450, 500), unitMbps)
+
+	baseFile, curFile := dir+"/base.csv", dir+"/cur.yaml"
+	if err := exportCsv(baseFile, &baseline); err != nil {
+		t.Fatalf("exportCsv: %v", err)
+	}
+	if err := export(curFile, &current); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	base, errBase := ReadExport(baseFile)
+	cur, errCur := ReadExport(curFile)
+	if errBase != nil || errCur != nil {
+		t.Fatalf("ReadExport: %v %v", errBase, errCur)
+	}
+	if base.Unit != "Mbps" || base.Input.YValues[2] != 1100 || !base.Input.XValues[1].Equal(now.Add(time.Second)) {
+		t.Errorf("csv should load back in Mbps: %+v", base)
+	}
+
+	regressions := map[string]bool{}
+	for _, c := range CompareExports(base, cur, 0.05) {
+		if c.Regression {
+			regressions[c.Direction+" "+c.Metric] = true
+		}
+	}
+	// input p10 improved, p90 dropped 2.7%; output mean dropped 10%
+	if len(regressions) != 3 || !regressions["output mean"] || !regressions["output p10"] || !regressions["output p50"] {
+		t.Errorf("regressions: %v", regressions)
+	}
+
+	if got := percentile([]float64{1, 2, 3, 4, 5}, 90); math.Abs(got-4.6) > 1e-9 {
+		t.Errorf("percentile: %v", got)
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	for _, bad := range []Config{
+		{MaxLoss: "1"}, // TCP
+		{MaxLoss: "1", UDP: true, Opt: Options{PassiveServer: true, UDPWriteSize: 1000}},
+		{MaxLoss: "101%", UDP: true, Opt: Options{UDPWriteSize: 1000}},
+		{MaxLoss: "1", UDP: true, Opt: Options{UDPWriteSize: 4}},
+		{MaxRTT: "10ms", UDP: true},
+		{MaxRTT: "-1s"},
+		{MinMbps: -1},
+	} {
+		if err := parseThresholds(&bad); err == nil {
+			t.Errorf("expected error for %+v", bad)
+		}
+	}
+
+	app := Config{MinMbps: 100, MaxRTT: "10ms"}
+	if err := parseThresholds(&app); err != nil {
+		t.Fatalf("parseThresholds: %v", err)
+	}
+	app.unit = unitMbps
+
+	ti := func(rtt time.Duration) ChartData {
+		return ChartData{TCPInfo: []TCPInfo{{RTT: rtt}}}
+	}
+	s := ClientStats{
+		ReadMbps:  150,
+		WriteMbps: 50,
+		Hosts: []HostStats{{Connections: []ConnStats{
+			{Read: DirStats{Intervals: ti(4 * time.Millisecond)}, Write: DirStats{Intervals: ti(8 * time.Millisecond)}},
+		}}},
+	}
+	checks := app.checkThresholds(s)
+	if len(checks) != 3 || checks[0].Pass || !checks[1].Pass || !checks[2].Pass {
+		t.Errorf("checks: %+v", checks)
+	}
+	if checks[2].Result != "6ms <= 10ms" {
+		t.Errorf("rtt: %q", checks[2].Result)
+	}
+	s.Thresholds = checks
+	if s.Passed() {
+		t.Errorf("expected failure: %+v", checks)
+	}
+
+	app.PassiveClient = true // send rate not checked
+	if checks := app.checkThresholds(s); len(checks) != 2 || !checks[0].Pass {
+		t.Errorf("passive client checks: %+v", checks)
+	}
+
+	if checks := app.checkThresholds(ClientStats{ReadMbps: 150}); checks[1].Pass {
+		t.Errorf("unmeasured rtt must fail: %+v", checks)
+	}
+
+	udp := Config{MaxLoss: "0.5%", UDP: true, Opt: Options{UDPWriteSize: 1000}}
+	if err := parseThresholds(&udp); err != nil || !udp.Opt.SeqNumbers {
+		t.Fatalf("parseThresholds: %v seqNumbers=%v", err, udp.Opt.SeqNumbers)
+	}
+	if checks := udp.checkThresholds(ClientStats{ReceivedDatagrams: 995, LostDatagrams: 5}); len(checks) != 1 || !checks[0].Pass {
+		t.Errorf("loss 0.5%%: %+v", checks)
+	}
+	if checks := udp.checkThresholds(ClientStats{ReceivedDatagrams: 99, LostDatagrams: 1}); checks[0].Pass {
+		t.Errorf("loss 1%%: %+v", checks)
+	}
+}
+
+func TestLossCounter(t *testing.T) {
+	var sent [][]byte
+	write := seqWrite(func(p []byte) (int, error) {
+		sent = append(sent, append([]byte{}, p...))
+		return len(p), nil
+	})
+	buf := make([]byte, 100)
+	for range 10 {
+		_, _ = write(buf)
+	}
+
+	var l lossCounter
+	for i, p := range sent {
+		if i == 3 || i == 6 {
+			continue // lost
+		}
+		if i == 4 {
+			l.count(sent[5]) // reordered
+			l.count(p)
+			continue
+		}
+		if i == 5 {
+			continue
+		}
+		l.count(p)
+	}
+	if l.received != 8 || l.lost() != 2 {
+		t.Errorf("received=%d lost=%d", l.received, l.lost())
+	}
+}
+
+func TestRenderExports(t *testing.T) {
+	now := time.Now()
+	chart := func(start time.Time, values ...float64) ChartData {
+		var data ChartData
+		for i, v := range values {
+			data.XValues = append(data.XValues, start.Add(time.Duration(i+1)*time.Second))
+			data.YValues = append(data.YValues, v)
+		}
+		return data
+	}
+	runs := []RenderRun{
+		{Name: "a", Info: &ExportInfo{Unit: "Mbps", Input: chart(now, 1000, 2000), Output: chart(now, 500, 500)}},
+		{Name: "b", Info: &ExportInfo{Unit: "Mbps", Input: chart(now.Add(time.Hour), 1500, 1500, 1500)}},
+	}
+
+	gbps, _ := lookupRateUnit("Gbps")
+	series := runSeries(runs, gbps)
+	if len(series) != 3 || series[0].name != "a input" || series[2].name != "b input" {
+		t.Fatalf("series: %+v", series)
+	}
+	// runs share the elapsed time axis
+	if series[2].x[0] != 0 || series[2].x[2] != 2 || series[0].y[1] != 2 {
+		t.Errorf("series b: %+v", series[2])
+	}
+	if single := runSeries(runs[:1], gbps); single[0].name != "input" {
+		t.Errorf("single run: %+v", single)
+	}
+
+	for _, format := range RenderFormats {
+		var out bytes.Buffer
+		if err := RenderExports(&out, runs, RenderOptions{Format: format, Unit: "auto", Title: "t"}); err != nil || out.Len() == 0 {
+			t.Errorf("%s: %v", format, err)
+		}
+		if format == "html" && (!strings.Contains(out.String(), "<svg") || !strings.Contains(out.String(), "MEAN Gbps")) {
+			t.Errorf("html: %s", out.String())
+		}
+		if format == "ascii" && !strings.Contains(out.String(), "* b input") {
+			t.Errorf("ascii: %s", out.String())
+		}
+	}
+
+	// a single interval has no time range of its own
+	one := []RenderRun{{Name: "one", Info: &ExportInfo{Unit: "Mbps", Input: chart(now, 1000)}}}
+	for _, format := range RenderFormats {
+		if err := RenderExports(io.Discard, one, RenderOptions{Format: format, Width: 400, Height: 200}); err != nil {
+			t.Errorf("single interval: %s: %v", format, err)
+		}
+	}
+
+	if err := RenderExports(io.Discard, runs, RenderOptions{Format: "gif"}); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+	if err := RenderExports(io.Discard, []RenderRun{{Name: "empty", Info: &ExportInfo{}}}, RenderOptions{Format: "svg"}); err == nil {
+		t.Errorf("expected error without data")
+	}
+	if err := RenderExports(io.Discard, runs, RenderOptions{Format: "svg", Width: -400, Height: 200}); err == nil {
+		t.Errorf("expected error for bad size")
+	}
+
+	for filename, format := range map[string]string{"x.PNG": "png", "x.htm": "html", "x.txt": "ascii", "x.svg": "svg", "x.gif": "", "x": ""} {
+		if got := RenderFormat(filename); got != format {
+			t.Errorf("RenderFormat(%s): %q, expected %q", filename, got, format)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	now := time.Now()
+	chart := func(values ...float64) ChartData {
+		var data ChartData
+		for i, v := range values {
+			data.XValues = append(data.XValues, now.Add(time.Duration(i)*time.Second))
+			data.YValues = append(data.YValues, v)
+		}
+		return data
+	}
+
+	later := chart(10, 20)
+	for i := range later.XValues {
+		later.XValues[i] = later.XValues[i].Add(time.Second + 100*time.Millisecond) // ramped up
+	}
+	agg := sumIntervals([]ChartData{chart(1, 2, 3), later}, time.Second)
+	if len(agg.YValues) != 3 || agg.YValues[0] != 1 || agg.YValues[1] != 12 || agg.YValues[2] != 23 || !agg.XValues[2].Equal(now.Add(2*time.Second)) {
+		t.Errorf("sumIntervals: %v", agg.YValues)
+	}
+
+	app := Config{Hosts: HostList{"h1"}, Connections: 2, Opt: Options{ReportInterval: time.Second}, unit: unitMbps, targets: []hostTarget{{host: "h1:8080", connections: 2}}}
+	conn := func(i int) ConnStats {
+		return ConnStats{Host: "h1:8080", Index: i, Proto: "TCP", Read: DirStats{Mbps: 100, Intervals: chart(90, 110)}, Write: DirStats{Mbps: 50, Intervals: chart(50, 50)}}
+	}
+	s := ClientStats{
+		ReadMbps:   200,
+		WriteMbps:  100,
+		Hosts:      []HostStats{{Host: "h1:8080", Connections: []ConnStats{conn(0), conn(1)}, ReadMbps: 200, WriteMbps: 100}},
+		Thresholds: []ThresholdCheck{{Name: "recv rate", Result: "200.00 Mbps >= 300.00 Mbps"}},
+	}
+
+	var out bytes.Buffer
+	if err := writeReport(&out, &app, s); err != nil {
+		t.Fatalf("writeReport: %v", err)
+	}
+	page := out.String()
+	if strings.Count(page, "<svg") != 3 || strings.Count(page, "<details>") != 2 {
+		t.Errorf("expected aggregate and 2 connection charts")
+	}
+	for _, expected := range []string{"<th>Connections</th><td>2</td>", "h1:8080 connection 1: TCP", `class="fail">FAIL`, "total", "&gt;= 300.00 Mbps"} {
+		if !strings.Contains(page, expected) {
+			t.Errorf("report missing %q", expected)
+		}
+	}
+
+	// a connection with a single interval has no chart, and does not fail the report
+	short := conn(2)
+	short.Read.Intervals, short.Write.Intervals = chart(90), ChartData{}
+	s.Hosts[0].Connections = append(s.Hosts[0].Connections, short)
+	out.Reset()
+	if err := writeReport(&out, &app, s); err != nil {
+		t.Fatalf("writeReport with a short connection: %v", err)
+	}
+	if page := out.String(); strings.Count(page, "<svg") != 3 || !strings.Contains(page, "<p>not enough data</p>") {
+		t.Errorf("expected no chart for the short connection")
+	}
+
+	if _, err := parseExport([]string{"html", "svg"}); err != nil {
+		t.Errorf("parseExport: %v", err)
+	}
+	if err := ValidateAndUpdateConfig(&Config{Export: []string{"report-%d-%s.html"}}); err == nil {
+		t.Errorf("expected error for html filename with %%d")
+	}
+}
+
+func TestCharts(t *testing.T) {
+	if w, h, err := ParseChartSize("800X300"); err != nil || w != 800 || h != 300 {
+		t.Errorf("ParseChartSize: %d %d %v", w, h, err)
+	}
+	for _, bad := range []string{"800", "x300", "800x", "50x50", "axb"} {
+		if _, _, err := ParseChartSize(bad); err == nil {
+			t.Errorf("ParseChartSize(%q): expected error", bad)
+		}
+	}
+
+	for filename, expected := range map[string]string{
+		"result-%d-%s.png": "result-all-overlay.png",
+		"out/chart.svg":    "out/chart-overlay.svg",
+	} {
+		if got := overlayFilename(filename); got != expected {
+			t.Errorf("overlayFilename(%s): %s", filename, got)
+		}
+	}
+
+	now := time.Now()
+	data := ChartData{XValues: []time.Time{now.Add(time.Second), now.Add(2 * time.Second)}, YValues: []float64{100, 300}}
+	line := chartLine("in", "input", data, now, 100)
+	if line.x[1] != 2 || line.y[1] != 3 {
+		t.Errorf("chartLine: %+v", line)
+	}
+
+	series := []renderSeries{line, chartLine("out", "output", data, now, 1)}
+	for _, style := range []chartStyle{{}, {title: "t", width: 300, height: 200, secondaryAxis: true}} {
+		var out bytes.Buffer
+		if err := renderChart(&out, rendererProvider("svg"), series, "Mbps", style); err != nil {
+			t.Errorf("renderChart %+v: %v", style, err)
+		}
+		if style.width == 300 && !strings.Contains(out.String(), `width="300" height="200"`) {
+			t.Errorf("chart size: %.100s", out.String())
+		}
+	}
+
+	app := Config{Hosts: HostList{"h1"}, Opt: Options{ReportInterval: time.Second}, unit: unitMbps}
+	conn := ConnStats{Host: "h1:8080", Read: DirStats{Intervals: data}, Write: DirStats{Intervals: data}}
+	s := ClientStats{Hosts: []HostStats{{Connections: []ConnStats{conn, conn}}}}
+	filename := t.TempDir() + "/overlay.png"
+	if err := overlayRender(context.Background(), filename, "png", &app, s); err != nil {
+		t.Errorf("overlayRender: %v", err)
+	}
+	if err := overlayRender(context.Background(), filename, "png", &app, ClientStats{}); err == nil {
+		t.Errorf("overlayRender: expected error without data")
+	}
+}
+
+func TestLiveReporter(t *testing.T) {
+	var out bytes.Buffer
+	r := NewLiveReporter(&out)
+	t0 := time.Now()
+	for i, mbps := range []float64{10, 20, 30, 40} {
+		at := t0.Add(time.Duration(i) * time.Second)
+		r.Report(Report{Time: at, Conn: "0/2", Kind: "report", Label: "clientReader", Mbps: mbps})
+		r.Report(Report{Time: at, Conn: "1/2", Kind: "report", Label: "clientReader", Mbps: mbps})
+		r.Report(Report{Time: at, Conn: "0/2", Kind: "report", Label: "clientWriter", Mbps: 5})
+	}
+	r.Report(Report{Time: t0, Conn: "0/2", Kind: "average", Label: "clientWriter", Mbps: 5})
+	time.Sleep(2 * liveRedrawDelay)
+	r.mutex.Lock() // the redraw writes from a timer
+	redraw := out.String()
+	out.Reset()
+	r.mutex.Unlock()
+	if !strings.HasPrefix(redraw, liveClear) {
+		t.Errorf("expected the redraw to clear the screen: %q", redraw)
+	}
+	r.Summary(ClientStats{})
+
+	dashboard := out.String()
+	if strings.Contains(dashboard, liveClear) {
+		t.Errorf("expected the final dashboard to keep the exports above it: %q", dashboard)
+	}
+	for _, expected := range []string{
+		"goben live: 3 connection directions",
+		"0/2        clientReader       40.00 Mbps     25.00 Mbps  ▂▄▆█",
+		"0/2        clientWriter             done      5.00 Mbps  ████",
+		"Input aggregate Mbps: 80.00",
+		"Output aggregate Mbps: 5.00",
+	} {
+		if !strings.Contains(dashboard, expected) {
+			t.Errorf("missing %q in dashboard:\n%s", expected, dashboard)
+		}
+	}
+
+	if got := sumLatest([][]float64{{1, 2, 3}, {10}}); !slices.Equal(got, []float64{1, 2, 13}) {
+		t.Errorf("sumLatest: expected aligned on the latest interval, got %v", got)
+	}
+	if got := sparkline([]float64{0, 0}); got != "▁▁" {
+		t.Errorf("sparkline of zero rates: %q", got)
+	}
+
+	// the next run starts over
+	out.Reset()
+	r.Report(Report{Time: time.Now(), Conn: "0/1", Kind: "omit", Label: "clientReader", Mbps: 7})
+	r.Summary(ClientStats{})
+	if got := out.String(); !strings.Contains(got, "goben live: 1 connection directions") || !strings.Contains(got, "7.00 Mbps*") {
+		t.Errorf("unexpected dashboard of the next run:\n%s", got)
+	}
+}
+
+func TestSyncStartOldServer(t *testing.T) {
+	listener, errListen := net.Listen("tcp", "127.0.0.1:0")
+	if errListen != nil {
+		t.Fatalf("listen: %v", errListen)
+	}
+	defer listener.Close()
+
+	// an older server: acks without confirming the start message, then takes everything as traffic
+	first := make(chan string, 1)
+	go func() {
+		conn, errAccept := listener.Accept()
+		if errAccept != nil {
+			return
+		}
+		defer conn.Close()
+		var opt Options
+		if err := gob.NewDecoder(byteReader{conn}).Decode(&opt); err != nil {
+			first <- err.Error()
+			return
+		}
+		a := ack{Magic: ackMagic, Table: map[string]string{"serverVersion": "old"}}
+		if err := gob.NewEncoder(conn).Encode(&a); err != nil {
+			first <- err.Error()
+			return
+		}
+		buf := make([]byte, len(startMagic))
+		_, _ = io.ReadFull(conn, buf)
+		first <- string(buf)
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	app := NewDefaultConfig()
+	app.Hosts = HostList{listener.Addr().String()}
+	app.TLS = false
+	app.TotalDuration = "300ms"
+	app.Opt.SyncStart = true
+	app.Opt.PassiveServer = true
+	app.reporter = NopReporter{}
+	app.Export = []string{"none"}
+	if _, err := Open(context.Background(), app); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if got := <-first; got == startMagic {
+		t.Errorf("start message sent to a server that did not confirm it")
+	}
+}
+
+func TestSeqNumbersOldServer(t *testing.T) {
+	pc, errListen := net.ListenPacket("udp", "127.0.0.1:0")
+	if errListen != nil {
+		t.Fatalf("listen: %v", errListen)
+	}
+	defer pc.Close()
+
+	// an older UDP server: ignores SeqNumbers and sends plain payload, without an ack
+	go func() {
+		buf := make([]byte, 65536)
+		_, src, errRead := pc.ReadFrom(buf)
+		if errRead != nil {
+			return
+		}
+		payload := make([]byte, 1000)
+		for range 100 {
+			if _, err := pc.WriteTo(payload, src); err != nil {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	app := NewDefaultConfig()
+	app.Hosts = HostList{pc.LocalAddr().String()}
+	app.TLS = false
+	app.UDP = true
+	app.PassiveClient = true
+	app.TotalDuration = "300ms"
+	app.MaxLoss = "1"
+	app.Opt.UDPWriteSize = 1000
+	app.reporter = NopReporter{}
+	app.Export = []string{"none"}
+	stats, err := Open(context.Background(), app)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if stats.Passed() || stats.ReceivedDatagrams != 0 {
+		t.Errorf("payload of an older server counted for --maxLoss: %+v", stats.Thresholds)
+	}
+	var errs []string
+	for _, h := range stats.Hosts {
+		for _, c := range h.Connections {
+			errs = append(errs, c.Errors...)
+		}
+	}
+	if !strings.Contains(strings.Join(errs, "\n"), "sequence numbers") {
+		t.Errorf("missing sequence numbers error: %q", errs)
+	}
+}
+
+func TestVerifierTruncation(t *testing.T) {
+	opt := Options{Seed: 3, UDPWriteSize: 100, Bytes: 250}
+
+	// datagrams of 100, 100 and 50 bytes: the last one cut at the limit
+	sender := newVerifier(opt.Seed, streamUpload, true)
+	datagrams := make([][]byte, 3)
+	for i, size := range []int{100, 100, 50} {
+		datagrams[i] = make([]byte, size)
+		sender.fill(datagrams[i])
+	}
+
+	receiver := newReceiveVerifier(opt, streamUpload, true)
+	receiver.check(datagrams[0])
+	receiver.check(datagrams[1][:60]) // truncated in transit
+	receiver.check(datagrams[2])
+	if receiver.result.CorruptDatagrams != 1 || receiver.result.MissingBytes != 40 {
+		t.Errorf("truncated datagram: corrupt=%d missing=%d wanted 1 and 40",
+			receiver.result.CorruptDatagrams, receiver.result.MissingBytes)
+	}
+
+	// a TCP stream bounded only by bytes must arrive whole
+	streamSender := newVerifier(opt.Seed, streamUpload, false)
+	p := make([]byte, 200)
+	streamSender.fill(p)
+	stream := newReceiveVerifier(opt, streamUpload, false)
+	stream.check(p)
+	stream.finish()
+	if stream.result.MissingBytes != 50 || stream.result.CorruptBytes != 0 {
+		t.Errorf("short stream: missing=%d corrupt=%d wanted 50 and 0", stream.result.MissingBytes, stream.result.CorruptBytes)
+	}
+
+	// with a duration, the timer may end the stream anywhere
+	opt.TotalDuration = time.Second
+	timed := newReceiveVerifier(opt, streamUpload, false)
+	timed.check(make([]byte, 10))
+	timed.finish()
+	if timed.result.MissingBytes != 0 {
+		t.Errorf("timed stream: missing=%d wanted 0", timed.result.MissingBytes)
+	}
+}
+
+func TestHostStatsNames(t *testing.T) {
+	app := &Config{targets: []hostTarget{{host: "a:8080", connections: 1}, {host: "b:8080", connections: 1}}}
+	run := newClientRun(app)
+	run.hosts[0].errors = []string{"ramp up interrupted"} // b:8080 never opened
+
+	hosts := run.hostStats()
+	if len(hosts) != 2 || hosts[0].Host != "a:8080" || hosts[1].Host != "b:8080" {
+		t.Errorf("expected both hosts labeled, got %+v", hosts)
+	}
+}
@@ -0,0 +1,62 @@
+package goben
+
+import (
+	"errors"
+)
+
+// errLimitReached ends a work loop once its byte/block limit has been transferred.
+var errLimitReached = errors.New("transfer limit reached")
+
+// byteLimit returns the number of bytes to transfer in each direction
+// of one connection, 0 means unlimited.
+// Blocks are counted in write calls of the configured write size.
+func (opt Options) byteLimit(udp bool) int64 {
+	if opt.Bytes > 0 {
+		return opt.Bytes
+	}
+	if opt.Blocks > 0 {
+		_, bufSizeOut := getBufSize(opt, udp)
+		return opt.Blocks * int64(bufSizeOut)
+	}
+	return 0
+}
+
+// hasLimit reports whether the test is bounded by bytes or blocks.
+func (opt Options) hasLimit() bool {
+	return opt.Bytes > 0 || opt.Blocks > 0
+}
+
+// splitLimit splits a total limit across connections,
+// the first total%connections connections get one extra unit.
+func splitLimit(total int64, seq, connections int) int64 {
+	if total <= 0 || connections <= 0 {
+		return total
+	}
+	share := total / int64(connections)
+	if int64(seq) < total%int64(connections) {
+		share++
+	}
+	return share
+}
+
+// limitCall stops f after limit bytes, truncating the last call
+// so exactly limit bytes are transferred. limit <= 0 means unlimited.
+func limitCall(f call, limit int64) call {
+	if limit <= 0 {
+		return f
+	}
+	remaining := limit
+	return func(p []byte) (int, error) {
+		if remaining <= 0 {
+			return 0, errLimitReached
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+		n, err := f(p)
+		if n > 0 {
+			remaining -= int64(n)
+		}
+		return n, err
+	}
+}
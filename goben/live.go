@@ -0,0 +1,234 @@
+package goben
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guptarohit/asciigraph"
+)
+
+const (
+	liveHistory     = 60                    // interval rates kept per row, for the sparklines
+	liveRedrawDelay = 50 * time.Millisecond // coalesces the reports of all connections into one redraw
+	liveClear       = "\x1b[H\x1b[2J"       // cursor home, clear screen
+)
+
+// sparkChars are the levels of the per-connection sparklines.
+var sparkChars = []rune("▁▂▃▄▅▆▇█")
+
+// isTerminal reports whether w is a terminal, rather than a pipe, file or buffer.
+func isTerminal(w io.Writer) bool {
+	f, isFile := w.(*os.File)
+	if !isFile {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// LiveReporter redraws a dashboard of all connections on a terminal:
+// current and average rate and a sparkline of each direction of each
+// connection, and a chart of the aggregate rate per direction.
+type LiveReporter struct {
+	Unit string // rate unit, as in --unit, empty means Mbps
+
+	w       io.Writer
+	mutex   sync.Mutex
+	start   time.Time
+	rows    []*liveRow
+	index   map[string]*liveRow // by Conn and Label
+	pending bool                // redraw scheduled
+}
+
+// liveRow is one direction of one connection.
+type liveRow struct {
+	conn, label string
+	input       bool
+	warmUp      bool      // last report was omitted
+	current     float64   // Mbps of the last interval
+	sum         float64   // Mbps of the reported intervals, for the average
+	samples     int       // reported intervals
+	average     float64   // final average, once done
+	done        bool      // final average received
+	history     []float64 // last liveHistory interval rates, in Mbps
+}
+
+// NewLiveReporter creates a LiveReporter drawing on w, which should be a terminal.
+func NewLiveReporter(w io.Writer) *LiveReporter {
+	return &LiveReporter{w: w, index: map[string]*liveRow{}}
+}
+
+// Report updates the row of the connection and schedules a redraw.
+func (r *LiveReporter) Report(rep Report) {
+	if rep.Kind == "disk" {
+		return // rows show network rates
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.start.IsZero() {
+		r.start = rep.Time
+	}
+	key := rep.Conn + " " + rep.Label
+	row := r.index[key]
+	if row == nil {
+		row = &liveRow{conn: rep.Conn, label: rep.Label, input: strings.Contains(rep.Label, "Reader")}
+		r.index[key] = row
+		r.rows = append(r.rows, row)
+	}
+	switch rep.Kind {
+	case "average":
+		row.average, row.done = rep.Mbps, true
+	default:
+		row.current = rep.Mbps
+		row.warmUp = rep.Kind == "omit"
+		if !row.warmUp {
+			row.sum += rep.Mbps
+			row.samples++
+		}
+		row.history = append(row.history, rep.Mbps)
+		if len(row.history) > liveHistory {
+			row.history = row.history[len(row.history)-liveHistory:]
+		}
+	}
+
+	if !r.pending {
+		r.pending = true
+		time.AfterFunc(liveRedrawDelay, r.redraw)
+	}
+}
+
+func (r *LiveReporter) redraw() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.pending {
+		return // drawn by Summary
+	}
+	r.pending = false
+	r.draw(true)
+}
+
+// Summary draws the final dashboard, without clearing the exports
+// written since the last redraw, then the summary table below it,
+// and starts over for the next run.
+func (r *LiveReporter) Summary(s ClientStats) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.pending = false
+	r.draw(false)
+	fmt.Fprintln(r.w)
+	_ = WriteSummaryTable(r.w, s, r.Unit)
+	r.start = time.Time{}
+	r.rows = nil
+	r.index = map[string]*liveRow{}
+}
+
+// RepeatSummary writes the repeat statistics table.
+func (r *LiveReporter) RepeatSummary(s RepeatStats) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	fmt.Fprintln(r.w)
+	_ = WriteRepeatTable(r.w, s)
+}
+
+const fmtLiveRow = "%-10s %-14s %14s %14s  %s\n"
+
+// draw writes the dashboard in one write, to avoid flicker,
+// optionally clearing the terminal first.
+func (r *LiveReporter) draw(clear bool) {
+	unit := unitNamed(r.Unit)
+	var b strings.Builder
+	if clear {
+		b.WriteString(liveClear)
+	}
+	fmt.Fprintf(&b, "goben live: %d connection directions, %v since the first report\n\n", len(r.rows), time.Since(r.start).Round(time.Second))
+	fmt.Fprintf(&b, fmtLiveRow, "CONN", "LABEL", "CURRENT", "AVERAGE", "HISTORY")
+
+	var input, output [][]float64
+	var warmUp bool
+	for _, row := range r.rows {
+		current := formatRate(row.current, unit)
+		switch {
+		case row.done:
+			current = "done"
+		case row.warmUp:
+			current += "*"
+			warmUp = true
+		}
+		average := "-"
+		switch {
+		case row.done:
+			average = formatRate(row.average, unit)
+		case row.samples > 0:
+			average = formatRate(row.sum/float64(row.samples), unit)
+		}
+		fmt.Fprintf(&b, fmtLiveRow, row.conn, row.label, current, average, sparkline(row.history))
+		if row.input {
+			input = append(input, row.history)
+		} else {
+			output = append(output, row.history)
+		}
+	}
+	if warmUp {
+		b.WriteString("* warm-up interval, not averaged\n")
+	}
+
+	for _, d := range []struct {
+		name string
+		data [][]float64
+	}{{"Input", input}, {"Output", output}} {
+		total := sumLatest(d.data)
+		if len(total) < 2 {
+			continue // asciigraph needs a line
+		}
+		u := unit.forValue(total[len(total)-1])
+		for i := range total {
+			total[i] = u.scale(total[i])
+		}
+		caption := fmt.Sprintf("%s aggregate %s: %.2f", d.name, u.name, total[len(total)-1])
+		b.WriteString("\n")
+		b.WriteString(asciigraph.Plot(total, asciigraph.Caption(caption), asciigraph.Height(5), asciigraph.Width(liveHistory)))
+		b.WriteString("\n")
+	}
+
+	_, _ = io.WriteString(r.w, b.String()) // reporting is best effort
+}
+
+// sumLatest adds up histories aligned on their latest interval,
+// so that connections ramped up later still count in the last samples.
+func sumLatest(histories [][]float64) []float64 {
+	var n int
+	for _, h := range histories {
+		n = max(n, len(h))
+	}
+	sum := make([]float64, n)
+	for _, h := range histories {
+		offset := n - len(h)
+		for i, v := range h {
+			sum[offset+i] += v
+		}
+	}
+	return sum
+}
+
+// sparkline draws values as one line of block characters, scaled to their peak.
+func sparkline(values []float64) string {
+	var peak float64
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if peak > 0 {
+			i = int(v / peak * float64(len(sparkChars)-1))
+		}
+		b.WriteRune(sparkChars[min(max(i, 0), len(sparkChars)-1)])
+	}
+	return b.String()
+}
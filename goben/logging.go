@@ -0,0 +1,255 @@
+package goben
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// LevelSummary is the log level of final results: per-connection averages
+// and the client summary. It is above slog.LevelError so that results are
+// still logged when only warnings and errors are.
+const LevelSummary = slog.Level(12)
+
+// Log formats for --logFormat.
+const (
+	logFormatClassic = "classic"
+	logFormatText    = "text"
+	logFormatJSON    = "json"
+)
+
+// NewLogger creates a logger writing to w in one of the --logFormat formats:
+// classic (log package style lines), text (slog key=value) or json.
+func NewLogger(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: replaceLevel}
+	switch strings.ToLower(format) {
+	case logFormatClassic, "":
+		return slog.New(newClassicHandler(log.New(w, "", log.LstdFlags), level)), nil
+	case logFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case logFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("bad logFormat: %q (expected classic, text or json)", format)
+}
+
+// LogLevel returns the log level selected by --verbose, --quiet and --live.
+func (app *Config) LogLevel() slog.Level {
+	switch {
+	case app.Verbose:
+		return slog.LevelDebug
+	case app.Quiet, app.Live && isTerminal(app.getOutput()): // the live dashboard replaces progress logs
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+// updateLogger builds the logger selected by the log flags,
+// unless set by the library caller.
+func updateLogger(app *Config) error {
+	if app.logger != nil {
+		return nil
+	}
+	switch strings.ToLower(app.LogFormat) {
+	case logFormatClassic, "":
+		if app.LogLevel() != slog.LevelInfo {
+			app.logger = slog.New(newClassicHandler(log.Default(), app.LogLevel()))
+		}
+		return nil // nil logger writes through the global logger
+	}
+	logger, err := NewLogger(log.Writer(), app.LogFormat, app.LogLevel())
+	if err != nil {
+		return err
+	}
+	app.logger = logger
+	return nil
+}
+
+func levelName(level slog.Level) string {
+	if level == LevelSummary {
+		return "SUMMARY"
+	}
+	return level.String()
+}
+
+func replaceLevel(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			a.Value = slog.StringValue(levelName(level))
+		}
+	}
+	return a
+}
+
+// classicHandler writes records as goben always did, through a log.Logger:
+// date, time and message, preceded by the level unless INFO or SUMMARY.
+type classicHandler struct {
+	logger *log.Logger
+	level  slog.Level
+	attrs  string // preformatted attributes
+	group  string // key prefix
+}
+
+func newClassicHandler(logger *log.Logger, level slog.Level) *classicHandler {
+	return &classicHandler{logger: logger, level: level}
+}
+
+// defaultLogger writes through the global logger.
+var defaultLogger = slog.New(newClassicHandler(log.Default(), slog.LevelInfo))
+
+func (h *classicHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *classicHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if r.Level != slog.LevelInfo && r.Level != LevelSummary {
+		b.WriteString(levelName(r.Level))
+		b.WriteByte(' ')
+	}
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+	r.Attrs(func(a slog.Attr) bool {
+		h.appendAttr(&b, h.group, a)
+		return true
+	})
+	return h.logger.Output(0, b.String())
+}
+
+func (h *classicHandler) appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			h.appendAttr(b, prefix, ga)
+		}
+		return
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	fmt.Fprintf(b, " %s%s=%v", prefix, a.Key, a.Value)
+}
+
+func (h *classicHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		h.appendAttr(&b, h.group, a)
+	}
+	h2 := *h
+	h2.attrs += b.String()
+	return &h2
+}
+
+func (h *classicHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group += name + "."
+	return &h2
+}
+
+// runHooks carries the output destinations of a client or server run
+// through its context, so embedding programs can observe a run without
+// the global logger.
+type runHooks struct {
+	logger   *slog.Logger // nil means the global logger
+	reporter Reporter     // set by newRunHooks
+}
+
+// newRunHooks creates the output destinations of a run,
+// a nil reporter means a LogReporter on logger.
+func newRunHooks(logger *slog.Logger, reporter Reporter) *runHooks {
+	if reporter == nil {
+		reporter = NewLogReporter(logger)
+	}
+	return &runHooks{logger: logger, reporter: reporter}
+}
+
+// defaultReporter logs reports through the global logger, for runs without hooks.
+var defaultReporter = NewLogReporter(nil)
+
+type hooksKey struct{}
+
+func withHooks(ctx context.Context, h *runHooks) context.Context {
+	return context.WithValue(ctx, hooksKey{}, h)
+}
+
+// hooksFrom returns the hooks of the run, nil if none were installed.
+func hooksFrom(ctx context.Context) *runHooks {
+	h, _ := ctx.Value(hooksKey{}).(*runHooks)
+	return h
+}
+
+func (h *runHooks) getLogger() *slog.Logger {
+	if h == nil || h.logger == nil {
+		return defaultLogger
+	}
+	return h.logger
+}
+
+func (h *runHooks) logf(ctx context.Context, level slog.Level, format string, v ...any) {
+	logger := h.getLogger()
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+	logger.Log(ctx, level, fmt.Sprintf(format, v...))
+}
+
+func (h *runHooks) getReporter() Reporter {
+	if h == nil {
+		return defaultReporter
+	}
+	return h.reporter
+}
+
+func (h *runHooks) report(r Report) {
+	h.getReporter().Report(r)
+}
+
+func (h *runHooks) summary(s ClientStats) {
+	h.getReporter().Summary(s)
+}
+
+func (h *runHooks) repeatSummary(s RepeatStats) {
+	if r, ok := h.getReporter().(RepeatReporter); ok {
+		r.RepeatSummary(s)
+	}
+}
+
+// debugf logs goroutine lifecycle and protocol details.
+func debugf(ctx context.Context, format string, v ...any) {
+	hooksFrom(ctx).logf(ctx, slog.LevelDebug, format, v...)
+}
+
+// infof logs the progress of a run.
+func infof(ctx context.Context, format string, v ...any) {
+	hooksFrom(ctx).logf(ctx, slog.LevelInfo, format, v...)
+}
+
+// warnf logs failures a run survives, such as a connection that could not be established.
+func warnf(ctx context.Context, format string, v ...any) {
+	hooksFrom(ctx).logf(ctx, slog.LevelWarn, format, v...)
+}
+
+// errorf logs failures of a whole run.
+func errorf(ctx context.Context, format string, v ...any) {
+	hooksFrom(ctx).logf(ctx, slog.LevelError, format, v...)
+}
+
+// summaryf logs final results.
+func summaryf(ctx context.Context, format string, v ...any) {
+	hooksFrom(ctx).logf(ctx, LevelSummary, format, v...)
+}
+
+// SetLogger sends the log of runs of this config to logger, instead of
+// the one selected by the log flags. See also NewLogger.
+func (app *Config) SetLogger(logger *slog.Logger) {
+	app.logger = logger
+}
@@ -2,11 +2,10 @@ package goben
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
-	"errors"
 	"fmt"
 	"io"
-	"log"
 	"time"
 )
 
@@ -14,15 +13,36 @@ import (
 type Options struct {
 	ReportInterval time.Duration
 	TotalDuration  time.Duration
+	Omit           time.Duration // warm-up period excluded from averages
 	TCPReadSize    int
 	TCPWriteSize   int
 	UDPReadSize    int
 	UDPWriteSize   int
 	PassiveServer  bool              // suppress server send
+	SyncStart      bool              // server waits for start message after ack
+	Bytes          int64             // bytes to transfer in each direction (0 means unlimited)
+	Blocks         int64             // write calls to transfer in each direction (0 means unlimited)
+	Verify         bool              // send and check a deterministic pseudo-random stream
+	Seed           int64             // seed for the verified stream
+	SeqNumbers     bool              // server stamps UDP datagrams with sequence numbers, for --maxLoss
+	Payload        string            // payload generator (see --payload)
+	PayloadData    []byte            // payload file contents, for file payloads
 	MaxSpeed       float64           // mbps
+	TOS            int               // IP TOS / IPv6 traffic class marking (0 means unchanged)
 	Table          map[string]string // send optional information client->server
 }
 
+// String formats options for logging, summarizing the payload file contents.
+func (opt Options) String() string {
+	type plain Options // drop String method
+	p := plain(opt)
+	p.PayloadData = nil
+	if len(opt.PayloadData) == 0 {
+		return fmt.Sprintf("%v", p)
+	}
+	return fmt.Sprintf("%v payloadData=%d bytes", p, len(opt.PayloadData))
+}
+
 type ack struct {
 	Magic string
 	Table map[string]string // send optional information server->client
@@ -30,6 +50,18 @@ type ack struct {
 
 const ackMagic = "goben-ack"
 
+// ackSyncStart is set in the ack table by servers that wait for the start message,
+// since older servers would take it for test traffic.
+const ackSyncStart = "syncStart"
+
+// ackSeqNumbers is set in the ack table by servers that stamp UDP datagrams with
+// sequence numbers, since older servers ignore the option and --maxLoss would count
+// their payload as bogus losses. UDP servers send an ack only for this option.
+const ackSeqNumbers = "seqNumbers"
+
+// udpAckTimeout bounds the wait for the UDP ack, which older servers never send.
+const udpAckTimeout = 5 * time.Second
+
 func newAck() ack {
 	a := ack{
 		Magic: ackMagic,
@@ -40,13 +72,25 @@ func newAck() ack {
 	return a
 }
 
+// byteReader prevents gob from buffering past the end of a handshake
+// message, which would swallow the test traffic following it.
+type byteReader struct {
+	io.Reader
+}
+
+func (r byteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(r.Reader, b[:])
+	return b[0], err
+}
+
 // ackSend server sends
-func ackSend(udp bool, conn io.Writer, a ack) error {
+func ackSend(ctx context.Context, udp bool, conn io.Writer, a ack) error {
 
 	// prevent sending wrong magic
 	if a.Magic != ackMagic {
 		m := fmt.Sprintf("ackSend: bad magic: expected=[%s] got=[%s]", ackMagic, a.Magic)
-		log.Print(m)
+		warnf(ctx, "%s", m)
 		return fmt.Errorf("%s", m)
 	}
 
@@ -54,12 +98,12 @@ func ackSend(udp bool, conn io.Writer, a ack) error {
 		var buf bytes.Buffer
 		enc := gob.NewEncoder(&buf)
 		if errEnc := enc.Encode(&a); errEnc != nil {
-			log.Printf("ackSend: UDP encoding: %v", errEnc)
+			warnf(ctx, "ackSend: UDP encoding: %v", errEnc)
 			return errEnc
 		}
 		_, errWrite := conn.Write(buf.Bytes())
 		if errWrite != nil {
-			log.Printf("ackSend: UDP write: %v", errWrite)
+			warnf(ctx, "ackSend: UDP write: %v", errWrite)
 			return errWrite
 		}
 		return nil
@@ -67,7 +111,7 @@ func ackSend(udp bool, conn io.Writer, a ack) error {
 
 	enc := gob.NewEncoder(conn)
 	if errEnc := enc.Encode(&a); errEnc != nil {
-		log.Printf("ackSend: TCP failure: %v", errEnc)
+		warnf(ctx, "ackSend: TCP failure: %v", errEnc)
 		return errEnc
 	}
 
@@ -75,30 +119,66 @@ func ackSend(udp bool, conn io.Writer, a ack) error {
 }
 
 // ackRecv client receives
-func ackRecv(udp bool, conn io.Reader, a *ack) error {
+func ackRecv(ctx context.Context, udp bool, conn io.Reader, a *ack) error {
 
 	if udp {
-		const m = "ackRecv: UDP FIXME WRITEME"
-		log.Print(m)
-		return errors.New(m)
-	}
-
-	dec := gob.NewDecoder(conn)
-	if errDec := dec.Decode(a); errDec != nil {
-		log.Printf("ackRecv: TCP failure: %v", errDec)
-		return errDec
+		buf := make([]byte, 65536) // one datagram, of any size
+		n, errRead := conn.Read(buf)
+		if errRead != nil {
+			warnf(ctx, "ackRecv: UDP read: %v", errRead)
+			return errRead
+		}
+		dec := gob.NewDecoder(bytes.NewReader(buf[:n]))
+		if errDec := dec.Decode(a); errDec != nil {
+			warnf(ctx, "ackRecv: UDP decoding: %v", errDec)
+			return errDec
+		}
+	} else {
+		dec := gob.NewDecoder(byteReader{conn})
+		if errDec := dec.Decode(a); errDec != nil {
+			warnf(ctx, "ackRecv: TCP failure: %v", errDec)
+			return errDec
+		}
 	}
 
 	// prevent receiving wrong magic
 	if a.Magic != ackMagic {
 		m := fmt.Sprintf("ackRecv: bad magic: expected=[%s] got=[%s]", ackMagic, a.Magic)
-		log.Print(m)
+		warnf(ctx, "%s", m)
 		return fmt.Errorf("%s", m)
 	}
 
 	if serverVersion, ok := a.Table["serverVersion"]; ok {
-		log.Printf("serverVersion=%s", serverVersion)
+		debugf(ctx, "serverVersion=%s", serverVersion)
 	}
 
 	return nil
 }
+
+// startMagic is sent raw (not gob) so the receiver can read exactly
+// the message without buffering any test traffic that follows it.
+const startMagic = "goben-go"
+
+// startSend client sends when released from the start barrier
+func startSend(ctx context.Context, conn io.Writer) error {
+	if _, errWrite := io.WriteString(conn, startMagic); errWrite != nil {
+		warnf(ctx, "startSend: %v", errWrite)
+		return errWrite
+	}
+	return nil
+}
+
+// startRecv server receives before starting traffic
+func startRecv(ctx context.Context, conn io.Reader) error {
+	buf := make([]byte, len(startMagic))
+	if _, errRead := io.ReadFull(conn, buf); errRead != nil {
+		warnf(ctx, "startRecv: %v", errRead)
+		return errRead
+	}
+	if string(buf) != startMagic {
+		m := fmt.Sprintf("startRecv: bad magic: expected=[%s] got=[%q]", startMagic, buf)
+		warnf(ctx, "%s", m)
+		return fmt.Errorf("%s", m)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package goben
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Payload generators.
+const (
+	payloadRandom       = "random"
+	payloadZeros        = "zeros"
+	payloadText         = "text"
+	payloadCompressible = "compressible"
+	payloadFile         = "file"
+)
+
+const payloadTextPattern = "goben measures TCP/UDP transport layer throughput between hosts. "
+
+// payloadChunk is the unit over which the compressible generator mixes
+// random and zero bytes, small enough for any compressor window.
+const payloadChunk = 1024
+
+// payloadFileMaxUDP caps file payload data sent within UDP options.
+const payloadFileMaxUDP = 32 * 1024
+
+// parsePayload validates a --payload spec and loads file contents.
+// It returns the normalized spec and, for file payloads, up to maxSize bytes of data.
+func parsePayload(spec string, maxSize int) (string, []byte, error) {
+	mode, arg, _ := strings.Cut(spec, ":")
+	mode = strings.ToLower(strings.TrimSpace(mode))
+
+	switch mode {
+	case "", payloadRandom:
+		return payloadRandom, nil, nil
+	case payloadZeros, payloadText:
+		return mode, nil, nil
+	case payloadCompressible:
+		ratio, errRatio := strconv.ParseFloat(arg, 64)
+		if errRatio != nil || ratio < 0 || ratio > 1 {
+			return "", nil, fmt.Errorf("bad payload: %q: compressible ratio must be a number from 0 to 1", spec)
+		}
+		return fmt.Sprintf("%s:%g", payloadCompressible, ratio), nil, nil
+	case payloadFile:
+		data, errRead := readPayloadFile(arg, maxSize)
+		if errRead != nil {
+			return "", nil, fmt.Errorf("bad payload: %q: %w", spec, errRead)
+		}
+		return payloadFile, data, nil
+	}
+
+	return "", nil, fmt.Errorf("bad payload: %q (expected random, zeros, text, compressible:RATIO, or file:PATH)", spec)
+}
+
+func readPayloadFile(path string, maxSize int) ([]byte, error) {
+	f, errOpen := os.Open(path)
+	if errOpen != nil {
+		return nil, errOpen
+	}
+	defer f.Close()
+	data, errRead := io.ReadAll(io.LimitReader(f, int64(maxSize)))
+	if errRead != nil {
+		return nil, errRead
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty file: %s", path)
+	}
+	return data, nil
+}
+
+// payloadBuf creates a send buffer from the payload generator in opt.
+func payloadBuf(opt Options, size int) []byte {
+	mode, arg, _ := strings.Cut(opt.Payload, ":")
+
+	switch mode {
+	case payloadZeros:
+		return make([]byte, size)
+	case payloadText:
+		return repeatBuf([]byte(payloadTextPattern), size)
+	case payloadCompressible:
+		ratio, _ := strconv.ParseFloat(arg, 64)
+		return compressibleBuf(size, ratio)
+	case payloadFile:
+		if len(opt.PayloadData) > 0 {
+			return repeatBuf(opt.PayloadData, size)
+		}
+	}
+
+	return randBuf(size)
+}
+
+func repeatBuf(pattern []byte, size int) []byte {
+	buf := make([]byte, size)
+	for i := 0; i < size; i += len(pattern) {
+		copy(buf[i:], pattern)
+	}
+	return buf
+}
+
+// compressibleBuf fills each chunk with random bytes followed by zeros,
+// so that about ratio of the buffer is trivially compressible.
+func compressibleBuf(size int, ratio float64) []byte {
+	buf := randBuf(size)
+	zeros := int(ratio * payloadChunk)
+	for i := 0; i < size; i += payloadChunk {
+		chunk := buf[i:min(i+payloadChunk, size)]
+		clear(chunk[max(len(chunk)-zeros, 0):])
+	}
+	return buf
+}
@@ -1,14 +1,16 @@
 package goben
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
 	"os"
 
 	"github.com/guptarohit/asciigraph"
 )
 
-func plotasciiToFile(filename string, info *ExportInfo, remote string, index int) {
+// plotasciiToFile writes the ASCII charts of a connection to w and, optionally, to filename.
+func plotasciiToFile(ctx context.Context, w io.Writer, filename string, info *ExportInfo, remote string, index int) {
 
 	height := 10
 	width := 70
@@ -16,24 +18,24 @@ func plotasciiToFile(filename string, info *ExportInfo, remote string, index int
 	var buf string
 
 	if len(info.Input.YValues) > 0 {
-		caption := fmt.Sprintf("Input Mbps: %s Connection %d", remote, index)
-		log.Printf("%s input:", remote)
+		caption := fmt.Sprintf("Input %s: %s Connection %d", info.Unit, remote, index)
+		debugf(ctx, "%s input:", remote)
 		input := asciigraph.Plot(info.Input.YValues, asciigraph.Caption(caption), asciigraph.Height(height), asciigraph.Width(width))
-		fmt.Println(input)
+		fmt.Fprintln(w, input)
 		buf += input + "\n"
 	}
 
 	if len(info.Output.YValues) > 0 {
-		caption := fmt.Sprintf("Output Mbps: %s Connection %d", remote, index)
-		log.Printf("%s output:", remote)
+		caption := fmt.Sprintf("Output %s: %s Connection %d", info.Unit, remote, index)
+		debugf(ctx, "%s output:", remote)
 		output := asciigraph.Plot(info.Output.YValues, asciigraph.Caption(caption), asciigraph.Height(height), asciigraph.Width(width))
-		fmt.Println(output)
+		fmt.Fprintln(w, output)
 		buf += output + "\n"
 	}
 
 	if filename != "" && buf != "" {
 		if err := os.WriteFile(filename, []byte(buf), 0644); err != nil {
-			log.Printf("plotascii: write file %s: %v", filename, err)
+			warnf(ctx, "plotascii: write file %s: %v", filename, err)
 		}
 	}
 }
@@ -0,0 +1,241 @@
+package goben
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/guptarohit/asciigraph"
+	"github.com/wcharczuk/go-chart"
+)
+
+// RenderRun is one export to render, see ReadExport.
+type RenderRun struct {
+	Name string      // legend label, e.g. the export filename
+	Info *ExportInfo // rates in Mbps
+}
+
+// RenderOptions controls RenderExports.
+type RenderOptions struct {
+	Format string // png, svg, ascii or html
+	Unit   string // rate unit, as in --unit, empty means Mbps
+	Title  string // optional chart title
+	Width  int    // png and svg size in pixels, 0 means 1024x400
+	Height int
+}
+
+// RenderFormats lists the formats of RenderExports.
+var RenderFormats = []string{"png", "svg", "ascii", "html"}
+
+// RenderExports renders the interval rates of runs as one chart, with every
+// run overlaid on a shared time axis: seconds elapsed since its first sample.
+func RenderExports(w io.Writer, runs []RenderRun, opt RenderOptions) error {
+	unit := opt.Unit
+	if unit == "" {
+		unit = unitMbps.name
+	}
+	u, errUnit := lookupRateUnit(unit)
+	if errUnit != nil {
+		return errUnit
+	}
+	var peak float64
+	for _, r := range runs {
+		for _, v := range slices.Concat(r.Info.Input.YValues, r.Info.Output.YValues) {
+			peak = max(peak, v)
+		}
+	}
+	u = u.forValue(peak)
+
+	series := runSeries(runs, u)
+	if len(series) == 0 {
+		return fmt.Errorf("render: no data")
+	}
+
+	if (opt.Width != 0 || opt.Height != 0) && (opt.Width < 100 || opt.Height < 100) {
+		return fmt.Errorf("render: bad size: %dx%d (expected at least 100x100 pixels)", opt.Width, opt.Height)
+	}
+
+	style := chartStyle{title: opt.Title, width: opt.Width, height: opt.Height}
+	switch strings.ToLower(opt.Format) {
+	case "png", "svg":
+		return renderChart(w, rendererProvider(opt.Format), series, u.name, style)
+	case "ascii":
+		return renderASCII(w, series, u, opt.Title)
+	case "html":
+		style.title = ""
+		return renderHTML(w, runs, series, u, opt.Title, style)
+	}
+	return fmt.Errorf("render: unknown format: %q (expected %s)", opt.Format, strings.Join(RenderFormats, ", "))
+}
+
+// runSeries converts the directions of runs with data into chart lines.
+// A single run is labeled by direction only.
+func runSeries(runs []RenderRun, u rateUnit) []renderSeries {
+	var series []renderSeries
+	for _, r := range runs {
+		t0, found := firstSample(r.Info.Input, r.Info.Output)
+		if !found {
+			continue
+		}
+		for _, d := range []struct {
+			direction string
+			data      ChartData
+		}{{"input", r.Info.Input}, {"output", r.Info.Output}} {
+			if len(d.data.YValues) == 0 {
+				continue
+			}
+			name := d.direction
+			if len(runs) > 1 {
+				name = r.Name + " " + d.direction
+			}
+			series = append(series, chartLine(name, d.direction, d.data, t0, u.mbps))
+		}
+	}
+	return series
+}
+
+// asciiChars tells the overlaid runs of an ASCII chart apart.
+var asciiChars = []string{"", "*", "+", "o", "#", "x", "="}
+
+// renderASCII plots one chart per direction, one point per report interval.
+func renderASCII(w io.Writer, series []renderSeries, u rateUnit, title string) error {
+	if title != "" {
+		if _, err := fmt.Fprintln(w, title); err != nil {
+			return err
+		}
+	}
+	for _, direction := range []string{"input", "output"} {
+		var data [][]float64
+		var chars []asciigraph.CharSet
+		var legend []string
+		for _, s := range series {
+			if s.direction != direction {
+				continue
+			}
+			char := asciiChars[len(data)%len(asciiChars)]
+			cs := asciigraph.DefaultCharSet
+			if char != "" {
+				cs = asciigraph.CreateCharSet(char)
+			} else {
+				char = cs.Horizontal
+			}
+			data = append(data, s.y)
+			chars = append(chars, cs)
+			legend = append(legend, char+" "+s.name)
+		}
+		if len(data) == 0 {
+			continue
+		}
+		caption := fmt.Sprintf("%s %s", strings.ToUpper(direction[:1])+direction[1:], u.name)
+		if len(data) > 1 {
+			caption += ": " + strings.Join(legend, ", ")
+		}
+		plot := asciigraph.PlotMany(data, asciigraph.Caption(caption), asciigraph.Height(10), asciigraph.Width(70),
+			asciigraph.SeriesChars(chars...))
+		if _, err := fmt.Fprintln(w, plot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var renderTemplate = template.Must(template.New("render").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{.Chart}}
+<table>
+<tr><th>RUN</th><th>DIRECTION</th><th>SAMPLES</th><th>MEAN {{.Unit}}</th><th>P10</th><th>P50</th><th>P90</th><th>MAX</th></tr>
+{{range .Rows}}<tr><td>{{.Run}}</td><td>{{.Direction}}</td><td>{{.Samples}}</td>{{range .Values}}<td>{{printf "%.2f" .}}</td>{{end}}</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type renderRow struct {
+	Run       string
+	Direction string
+	Samples   int
+	Values    []float64
+}
+
+// renderHTML writes a self-contained page with the SVG chart and
+// the mean and percentiles of each run, as in goben compare.
+func renderHTML(w io.Writer, runs []RenderRun, series []renderSeries, u rateUnit, title string, style chartStyle) error {
+	svg, errChart := inlineSVG(series, u, style)
+	if errChart != nil {
+		return errChart
+	}
+
+	if title == "" {
+		title = "goben"
+	}
+	page := struct {
+		Title string
+		Chart template.HTML
+		Unit  string
+		Rows  []renderRow
+	}{Title: title, Chart: svg, Unit: u.name}
+
+	for _, r := range runs {
+		for _, d := range []struct {
+			direction string
+			data      ChartData
+		}{{"input", r.Info.Input}, {"output", r.Info.Output}} {
+			if len(d.data.YValues) == 0 {
+				continue
+			}
+			row := renderRow{Run: r.Name, Direction: d.direction, Samples: len(d.data.YValues)}
+			for _, m := range rateMetrics(d.data) { // mean, p10, p50, p90
+				row.Values = append(row.Values, m.mbps/u.mbps)
+			}
+			row.Values = append(row.Values, slices.Max(d.data.YValues)/u.mbps)
+			page.Rows = append(page.Rows, row)
+		}
+	}
+
+	return renderTemplate.Execute(w, page)
+}
+
+// inlineSVG renders a chart for embedding in an HTML page.
+func inlineSVG(series []renderSeries, u rateUnit, style chartStyle) (template.HTML, error) {
+	var svg bytes.Buffer
+	if err := renderChart(&svg, chart.SVG, series, u.name, style); err != nil {
+		return "", err
+	}
+	// go-chart writes a literal \n after the <svg> tag
+	return template.HTML(strings.Replace(svg.String(), `>\n<`, "><", 1)), nil
+}
+
+// RenderFormat infers the format of RenderExports from a filename extension,
+// or returns "" for an unknown extension.
+func RenderFormat(filename string) string {
+	i := strings.LastIndex(filename, ".")
+	if i < 0 {
+		return ""
+	}
+	ext := strings.ToLower(filename[i+1:])
+	if ext == "htm" {
+		ext = "html"
+	}
+	if ext == "txt" {
+		ext = "ascii"
+	}
+	if slices.Contains(RenderFormats, ext) {
+		return ext
+	}
+	return ""
+}
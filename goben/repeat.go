@@ -0,0 +1,261 @@
+package goben
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RunStats summarizes one metric across repeated runs.
+type RunStats struct {
+	Values []float64 // one value per successful run
+	Mean   float64
+	Median float64
+	StdDev float64 // sample standard deviation
+	CILow  float64 // 95% confidence interval of the mean
+	CIHigh float64
+	Min    float64
+	Max    float64
+}
+
+// RepeatStats summarizes repeated runs of a test, see --repeat.
+type RepeatStats struct {
+	Unit      string        // rate unit of ReadRate and WriteRate, as in --unit
+	Runs      []ClientStats `yaml:"-" json:"-"` // successful runs, in order
+	Errors    []string      // runs that failed
+	ReadRate  RunStats      // aggregate reading rate of each run
+	WriteRate RunStats      // aggregate writing rate of each run
+}
+
+// RepeatReporter is implemented by reporters that summarize repeated runs.
+type RepeatReporter interface {
+	RepeatSummary(s RepeatStats)
+}
+
+// tTable holds the two-sided 95% Student's t critical values for 1 to 30
+// degrees of freedom. Beyond, the normal approximation is close enough.
+var tTable = []float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+func tCritical(df int) float64 {
+	if df <= len(tTable) {
+		return tTable[df-1]
+	}
+	return 1.960
+}
+
+// newRunStats computes the statistics of values.
+func newRunStats(values []float64) RunStats {
+	s := RunStats{Values: values}
+	n := len(values)
+	if n == 0 {
+		return s
+	}
+
+	sorted := slices.Sorted(slices.Values(values))
+	s.Min, s.Max = sorted[0], sorted[n-1]
+	if n%2 == 1 {
+		s.Median = sorted[n/2]
+	} else {
+		s.Median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	s.Mean = sum / float64(n)
+
+	s.CILow, s.CIHigh = s.Mean, s.Mean
+	if n < 2 {
+		return s
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - s.Mean) * (v - s.Mean)
+	}
+	s.StdDev = math.Sqrt(squares / float64(n-1))
+	margin := tCritical(n-1) * s.StdDev / math.Sqrt(float64(n))
+	s.CILow, s.CIHigh = s.Mean-margin, s.Mean+margin
+	return s
+}
+
+// scaled converts the rates from Mbps to unit u.
+func (s RunStats) scaled(u rateUnit) RunStats {
+	values := make([]float64, len(s.Values))
+	for i, v := range s.Values {
+		values[i] = v / u.mbps
+	}
+	return RunStats{
+		Values: values,
+		Mean:   s.Mean / u.mbps,
+		Median: s.Median / u.mbps,
+		StdDev: s.StdDev / u.mbps,
+		CILow:  s.CILow / u.mbps,
+		CIHigh: s.CIHigh / u.mbps,
+		Min:    s.Min / u.mbps,
+		Max:    s.Max / u.mbps,
+	}
+}
+
+// newRepeatStats summarizes runs, with rates converted to unit,
+// auto picks one unit for both directions.
+func newRepeatStats(runs []ClientStats, errs []string, unit rateUnit) RepeatStats {
+	var read, write []float64
+	var peak float64
+	for _, r := range runs {
+		read = append(read, r.ReadMbps)
+		write = append(write, r.WriteMbps)
+		peak = max(peak, r.ReadMbps, r.WriteMbps)
+	}
+	unit = unit.forValue(peak)
+	return RepeatStats{
+		Unit:      unit.name,
+		Runs:      runs,
+		Errors:    errs,
+		ReadRate:  newRunStats(read).scaled(unit),
+		WriteRate: newRunStats(write).scaled(unit),
+	}
+}
+
+func parseRepeat(app *Config) error {
+	if app.Repeat < 1 {
+		return fmt.Errorf("bad repeat: %d: must be at least 1", app.Repeat)
+	}
+	pause, err := time.ParseDuration(app.RepeatPause)
+	if err != nil {
+		return fmt.Errorf("bad repeatPause: %q: %w", app.RepeatPause, err)
+	}
+	if pause < 0 {
+		return fmt.Errorf("bad repeatPause: %q: negative duration", app.RepeatPause)
+	}
+	app.repeatPause = pause
+	return nil
+}
+
+// OpenRepeat performs the test --repeat times, pausing --repeatPause between
+// runs, then reports the statistics of the per-run averages and exports them
+// along with the yaml and csv exports. The exports of each run are prefixed
+// with run-N-. A failed run is recorded and the next one runs.
+func OpenRepeat(ctx context.Context, app *Config) (RepeatStats, error) {
+	if err := ValidateAndUpdateConfig(app); err != nil {
+		return RepeatStats{}, err
+	}
+
+	ctx = withHooks(ctx, app.hooks())
+
+	var runs []ClientStats
+	var errs []string
+	for i := range app.Repeat {
+		if i > 0 && !rampWait(ctx, app.repeatPause) {
+			break
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		infof(ctx, "repeat: run %d/%d", i+1, app.Repeat)
+		run := *app
+		run.exportPrefix = app.exportPrefix + fmt.Sprintf("run-%d-", i+1)
+		stats, err := Open(ctx, &run)
+		if err != nil {
+			warnf(ctx, "repeat: run %d/%d: %v", i+1, app.Repeat, err)
+			errs = append(errs, fmt.Sprintf("run %d: %v", i+1, err))
+			continue
+		}
+		runs = append(runs, stats)
+	}
+
+	if len(runs) == 0 {
+		return RepeatStats{Errors: errs}, fmt.Errorf("repeat: no successful runs")
+	}
+
+	s := newRepeatStats(runs, errs, app.unit)
+
+	hooksFrom(ctx).repeatSummary(s)
+
+	for _, t := range app.exports {
+		if t.Filename == "" || (t.Mode != "yaml" && t.Mode != "csv") {
+			continue
+		}
+		filename := app.exportFilename(filepath.Join(filepath.Dir(t.Filename), "repeat."+t.Mode))
+		infof(ctx, "exporting repeat statistics to: %s", filename)
+		if errExport := exportRepeat(filename, t.Mode, s); errExport != nil {
+			warnf(ctx, "repeat: export %s: %v", filename, errExport)
+		}
+	}
+
+	return s, nil
+}
+
+func exportRepeat(filename, mode string, s RepeatStats) error {
+	out, errCreate := os.Create(filename)
+	if errCreate != nil {
+		return errCreate
+	}
+
+	var errWrite error
+	if mode == "yaml" {
+		errWrite = yaml.NewEncoder(out).Encode(s)
+	} else {
+		errWrite = writeRepeatCsv(out, s)
+	}
+	if errWrite != nil {
+		out.Close()
+		return errWrite
+	}
+	return out.Close()
+}
+
+func writeRepeatCsv(out io.Writer, s RepeatStats) error {
+	w := csv.NewWriter(out)
+	rows := [][]string{{"DIRECTION", "RUNS", "MEAN", "MEDIAN", "STDDEV", "CI_LOW", "CI_HIGH", "MIN", "MAX", "UNIT"}}
+	for _, d := range []struct {
+		dir string
+		rs  RunStats
+	}{{"input", s.ReadRate}, {"output", s.WriteRate}} {
+		row := []string{d.dir, fmt.Sprintf("%d", len(d.rs.Values))}
+		for _, v := range []float64{d.rs.Mean, d.rs.Median, d.rs.StdDev, d.rs.CILow, d.rs.CIHigh, d.rs.Min, d.rs.Max} {
+			row = append(row, fmt.Sprintf("%v", v))
+		}
+		rows = append(rows, append(row, s.Unit))
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+// WriteRepeatTable writes the statistics of repeated runs.
+func WriteRepeatTable(w io.Writer, s RepeatStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "REPEAT %s\tRUNS\tMEAN\tMEDIAN\tSTDDEV\t95%% CI\tMIN-MAX\n", s.Unit)
+	for _, d := range []struct {
+		label string
+		rs    RunStats
+	}{{"send", s.WriteRate}, {"recv", s.ReadRate}} {
+		rs := d.rs
+		fmt.Fprintf(tw, "%s\t%d\t%.2f\t%.2f\t%.2f\t%.2f-%.2f\t%.2f-%.2f\n", d.label, len(rs.Values),
+			rs.Mean, rs.Median, rs.StdDev, rs.CILow, rs.CIHigh, rs.Min, rs.Max)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, e := range s.Errors {
+		if _, err := fmt.Fprintf(w, "failed %s\n", e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
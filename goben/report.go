@@ -0,0 +1,206 @@
+package goben
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>goben report {{.Hosts}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+pre { background: #f6f6f6; padding: 1em; }
+summary { cursor: pointer; margin: 0.5em 0; }
+.pass { color: #080; font-weight: bold; }
+.fail { color: #c00; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>goben report</h1>
+<table>
+{{range .Metadata}}<tr><th>{{.Name}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{if .Thresholds}}<h2>Thresholds: {{if .Passed}}<span class="pass">PASS</span>{{else}}<span class="fail">FAIL</span>{{end}}</h2>
+<table>
+{{range .Thresholds}}<tr><th>{{.Name}}</th><td>{{.Result}}</td><td class="{{if .Pass}}pass">ok{{else}}fail">FAIL{{end}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Summary</h2>
+<pre>{{.Summary}}</pre>
+<h2>Aggregate</h2>
+{{.Aggregate}}
+<h2>Connections</h2>
+{{range .Connections}}<details>
+<summary>{{.Title}}</summary>
+{{.Chart}}
+</details>
+{{end}}</body>
+</html>
+`))
+
+type reportItem struct {
+	Name  string
+	Value string
+}
+
+type reportChart struct {
+	Title string
+	Chart template.HTML
+}
+
+// exportReport writes the html export of a run to filename.
+func exportReport(ctx context.Context, filename string, app *Config, s ClientStats) error {
+	out, errCreate := os.Create(filename)
+	if errCreate != nil {
+		return errCreate
+	}
+	if err := writeReport(out, app, s); err != nil {
+		out.Close()
+		return err
+	}
+	debugf(ctx, "exportReport: %d connections", len(s.Hosts))
+	return out.Close()
+}
+
+// writeReport writes a self-contained HTML page with the metadata, thresholds
+// and summary table of a run, the chart of the aggregate rates and,
+// collapsed, the chart of each connection.
+func writeReport(w io.Writer, app *Config, s ClientStats) error {
+	var peak float64
+	var input, output []ChartData
+	for _, h := range s.Hosts {
+		for _, c := range h.Connections {
+			for _, v := range slices.Concat(c.Read.Intervals.YValues, c.Write.Intervals.YValues) {
+				peak = max(peak, v)
+			}
+			input = append(input, c.Read.Intervals)
+			output = append(output, c.Write.Intervals)
+		}
+	}
+	aggInput, aggOutput := sumIntervals(input, app.Opt.ReportInterval), sumIntervals(output, app.Opt.ReportInterval)
+	for _, v := range slices.Concat(aggInput.YValues, aggOutput.YValues) {
+		peak = max(peak, v)
+	}
+	u := app.unit.forValue(peak)
+
+	var summary strings.Builder
+	if err := WriteSummaryTable(&summary, s, u.name); err != nil {
+		return err
+	}
+
+	page := struct {
+		Hosts       string
+		Metadata    []reportItem
+		Thresholds  []ThresholdCheck
+		Passed      bool
+		Summary     string
+		Aggregate   template.HTML
+		Connections []reportChart
+	}{
+		Hosts:      strings.Join(app.Hosts, ", "),
+		Metadata:   reportMetadata(app, s),
+		Thresholds: s.Thresholds,
+		Passed:     s.Passed(),
+		Summary:    summary.String(),
+	}
+
+	var errChart error
+	page.Aggregate, errChart = svgChart(&ExportInfo{Input: aggInput, Output: aggOutput}, u, app.chartStyle(""))
+	if errChart != nil {
+		return errChart
+	}
+	for _, h := range s.Hosts {
+		for _, c := range h.Connections {
+			svg, err := svgChart(&ExportInfo{Input: c.Read.Intervals, Output: c.Write.Intervals}, u, app.chartStyle(""))
+			if err != nil {
+				return err
+			}
+			title := fmt.Sprintf("%s connection %d: %s %s, recv %s, send %s", c.Host, c.Index, c.Proto, c.Remote,
+				formatRate(c.Read.Mbps, u), formatRate(c.Write.Mbps, u))
+			page.Connections = append(page.Connections, reportChart{Title: title, Chart: svg})
+		}
+	}
+
+	return reportTemplate.Execute(w, page)
+}
+
+func reportMetadata(app *Config, s ClientStats) []reportItem {
+	direction := "both"
+	switch {
+	case app.PassiveClient:
+		direction = "receive"
+	case app.Opt.PassiveServer:
+		direction = "send"
+	}
+	protocols := map[string]bool{}
+	var proto []string
+	for _, h := range s.Hosts {
+		for _, c := range h.Connections {
+			if !protocols[c.Proto] {
+				protocols[c.Proto] = true
+				proto = append(proto, c.Proto)
+			}
+		}
+	}
+	maxSpeed := "unlimited"
+	if app.Opt.MaxSpeed > 0 {
+		maxSpeed = formatRate(app.Opt.MaxSpeed, app.unit)
+	}
+	return []reportItem{
+		{"Generated", time.Now().Format(time.RFC3339)},
+		{"Version", "goben " + Version + " " + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH},
+		{"Hosts", strings.Join(app.Hosts, ", ")},
+		{"Protocol", strings.Join(proto, ", ")},
+		{"Connections", fmt.Sprintf("%d", app.totalConnections())},
+		{"Direction", direction},
+		{"Duration", fmt.Sprintf("%v (transfer %v)", s.TotalDuration, s.Elapsed.Round(time.Millisecond))},
+		{"Report interval", app.Opt.ReportInterval.String()},
+		{"Max speed", maxSpeed},
+		{"Payload", app.Opt.Payload},
+	}
+}
+
+// svgChart renders the input and output rates of info, in Mbps, as inline SVG.
+func svgChart(info *ExportInfo, u rateUnit, style chartStyle) (template.HTML, error) {
+	series := runSeries([]RenderRun{{Info: info}}, u)
+	if len(series) == 0 {
+		return "<p>no data</p>", nil
+	}
+	if !slices.ContainsFunc(series, func(s renderSeries) bool { return len(s.x) > 1 }) {
+		return "<p>not enough data</p>", nil // a line needs 2 samples
+	}
+	return inlineSVG(series, u, style)
+}
+
+// sumIntervals adds up the interval rates of all connections reported
+// within the same report interval since the first sample, so that ramped
+// up connections count from their start.
+func sumIntervals(data []ChartData, interval time.Duration) ChartData {
+	var sum ChartData
+	t0, found := firstSample(data...)
+	if !found || interval <= 0 {
+		return sum
+	}
+	for _, d := range data {
+		for i, t := range d.XValues {
+			bucket := int(t.Sub(t0).Round(interval) / interval)
+			for len(sum.YValues) <= bucket {
+				sum.XValues = append(sum.XValues, t0.Add(time.Duration(len(sum.XValues))*interval))
+				sum.YValues = append(sum.YValues, 0)
+			}
+			sum.YValues[bucket] += d.YValues[i]
+		}
+	}
+	return sum
+}
@@ -0,0 +1,305 @@
+package goben
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Report is one periodic throughput sample, or the final average,
+// of one direction of one connection. With --sendFile or --writeDir,
+// a "disk" report follows the average: the disk-bound throughput
+// of the file, "read" or "write", without calls.
+type Report struct {
+	Time     time.Time
+	Conn     string   // connection index/connections
+	Kind     string   // "report", "omit" (warm-up period), "average" or "disk" (see above)
+	Label    string   // reporting side and direction, e.g. "clientReader"
+	Mbps     float64  // Megabit/s
+	Cps      int64    // Call/s
+	CpsLabel string   // "rcv/s" or "snd/s", or the disk operation of a "disk" report
+	TCPInfo  *TCPInfo `json:",omitempty"` // nil when unavailable
+}
+
+// Reporter receives the progress and results of a run.
+// Report is called concurrently by all connections.
+type Reporter interface {
+	Report(r Report)
+	Summary(s ClientStats) // client only, at the end of the run
+}
+
+// Reporter names for --reporter.
+const (
+	reporterLog   = "log"
+	reporterTable = "table"
+	reporterJSON  = "json"
+	reporterNone  = "none"
+)
+
+// newReporter creates a built-in reporter by name.
+// unit is a --unit name for the log and table reporters.
+func newReporter(name string, logger *slog.Logger, w io.Writer, unit string) (Reporter, error) {
+	switch strings.ToLower(name) {
+	case reporterLog, "":
+		r := NewLogReporter(logger)
+		r.Unit = unit
+		return r, nil
+	case reporterTable:
+		r := NewTableReporter(w)
+		r.Unit = unit
+		return r, nil
+	case reporterJSON:
+		return NewJSONReporter(w), nil
+	case reporterNone:
+		return NopReporter{}, nil
+	}
+	return nil, fmt.Errorf("bad reporter: %q (expected log, table, json or none)", name)
+}
+
+// SetOutput sends the output of the table, json and live reporters and the
+// ASCII charts of runs of this config to w, os.Stdout by default.
+func (app *Config) SetOutput(w io.Writer) {
+	app.output = w
+}
+
+func (app *Config) getOutput() io.Writer {
+	if app.output == nil {
+		return os.Stdout
+	}
+	return app.output
+}
+
+func updateReporter(app *Config) error {
+	if app.reporter != nil {
+		return nil // set by the library caller
+	}
+	if app.Live {
+		if out := app.getOutput(); isTerminal(out) {
+			r := NewLiveReporter(out)
+			r.Unit = app.Unit
+			app.reporter = r
+			return nil
+		}
+		app.hooks().logf(context.Background(), slog.LevelInfo, "live: output is not a terminal, reporting with --reporter=%s", app.Reporter)
+	}
+	r, err := newReporter(app.Reporter, app.logger, app.getOutput(), app.Unit)
+	if err != nil {
+		return err
+	}
+	app.reporter = r
+	return nil
+}
+
+const (
+	fmtReport  = "%s %7s %14s rate: %f %s %6d %s"
+	fmtTCPInfo = " rtt: %v cwnd: %d retrans: %d"
+	fmtDisk    = "%s %7s %14s rate: %f %s disk %s"
+)
+
+// LogReporter writes reports in the classic goben log format,
+// at info level, and averages and summaries at LevelSummary.
+type LogReporter struct {
+	Unit  string // rate unit, as in --unit, empty means Mbps
+	hooks *runHooks
+}
+
+// NewLogReporter creates a LogReporter, a nil logger means the global logger.
+func NewLogReporter(logger *slog.Logger) *LogReporter {
+	return &LogReporter{hooks: &runHooks{logger: logger}}
+}
+
+func (r *LogReporter) summaryf(format string, v ...any) {
+	r.hooks.logf(context.Background(), LevelSummary, format, v...)
+}
+
+// Report logs one report.
+func (r *LogReporter) Report(rep Report) {
+	level := slog.LevelInfo
+	if rep.Kind == "average" {
+		level = LevelSummary
+	}
+	u := unitNamed(r.Unit).forValue(rep.Mbps)
+	if rep.Kind == "disk" {
+		r.hooks.logf(context.Background(), LevelSummary, fmtDisk, rep.Conn, rep.Kind, rep.Label, u.scale(rep.Mbps), u.name, rep.CpsLabel)
+		return
+	}
+	if ti := rep.TCPInfo; ti != nil {
+		r.hooks.logf(context.Background(), level, fmtReport+fmtTCPInfo, rep.Conn, rep.Kind, rep.Label, u.scale(rep.Mbps), u.name, rep.Cps, rep.CpsLabel, ti.RTT, ti.Cwnd, ti.Retransmits)
+		return
+	}
+	r.hooks.logf(context.Background(), level, fmtReport, rep.Conn, rep.Kind, rep.Label, u.scale(rep.Mbps), u.name, rep.Cps, rep.CpsLabel)
+}
+
+// Summary logs the aggregate results.
+func (r *LogReporter) Summary(s ClientStats) {
+	unit := unitNamed(r.Unit)
+	read, write := unit.forValue(s.ReadMbps), unit.forValue(s.WriteMbps)
+	r.summaryf("aggregate reading: %f %s %d recv/s", read.scale(s.ReadMbps), read.name, s.ReadCps)
+	r.summaryf("aggregate writing: %f %s %d send/s", write.scale(s.WriteMbps), write.name, s.WriteCps)
+	if s.RetransmitsSampled {
+		r.summaryf("aggregate retransmits: %d", s.Retransmits)
+	}
+	if s.DiskReadMbps > 0 {
+		disk := unit.forValue(s.DiskReadMbps)
+		r.summaryf("aggregate disk read: %f %s", disk.scale(s.DiskReadMbps), disk.name)
+	}
+	if s.VerifiedBytes > 0 {
+		r.summaryf("aggregate verify: %d bytes checked, %d corrupted bytes, %d missing bytes, %d corrupted datagrams",
+			s.VerifiedBytes, s.CorruptBytes, s.MissingBytes, s.CorruptDatagrams)
+	}
+	if s.ReceivedDatagrams > 0 {
+		r.summaryf("aggregate loss: %d/%d datagrams (%.2f%%)",
+			s.LostDatagrams, s.ReceivedDatagrams+s.LostDatagrams, s.LossPercent())
+	}
+
+	var table strings.Builder
+	_ = WriteSummaryTable(&table, s, r.Unit)
+	for line := range strings.Lines(table.String()) {
+		r.summaryf("%s", strings.TrimSuffix(line, "\n"))
+	}
+}
+
+// RepeatSummary logs the statistics of repeated runs.
+func (r *LogReporter) RepeatSummary(s RepeatStats) {
+	var table strings.Builder
+	_ = WriteRepeatTable(&table, s)
+	for line := range strings.Lines(table.String()) {
+		r.summaryf("%s", strings.TrimSuffix(line, "\n"))
+	}
+}
+
+// TableReporter writes reports as compact table rows.
+type TableReporter struct {
+	Unit   string // rate unit, as in --unit, empty means Mbps
+	w      io.Writer
+	mutex  sync.Mutex
+	header bool
+}
+
+// NewTableReporter creates a TableReporter writing to w.
+func NewTableReporter(w io.Writer) *TableReporter {
+	return &TableReporter{w: w}
+}
+
+const fmtTableRow = "%-12s %-7s %-14s %-7s %14s %10s %10s\n"
+
+// Report writes one table row.
+func (r *TableReporter) Report(rep Report) {
+	rtt := "-"
+	if rep.TCPInfo != nil {
+		rtt = rep.TCPInfo.RTT.String()
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.header {
+		fmt.Fprintf(r.w, fmtTableRow, "TIME", "CONN", "LABEL", "KIND", "RATE", "CALLS/S", "RTT")
+		r.header = true
+	}
+	fmt.Fprintf(r.w, fmtTableRow, rep.Time.Format("15:04:05.000"), rep.Conn, rep.Label, rep.Kind,
+		formatRate(rep.Mbps, unitNamed(r.Unit)), fmt.Sprintf("%d", rep.Cps), rtt)
+}
+
+// Summary writes the summary table.
+func (r *TableReporter) Summary(s ClientStats) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	fmt.Fprintln(r.w)
+	_ = WriteSummaryTable(r.w, s, r.Unit)
+}
+
+// RepeatSummary writes the repeat statistics table.
+func (r *TableReporter) RepeatSummary(s RepeatStats) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	fmt.Fprintln(r.w)
+	_ = WriteRepeatTable(r.w, s)
+}
+
+// JSONReporter writes one JSON object per line for each report and the summary.
+type JSONReporter struct {
+	mutex sync.Mutex
+	enc   *json.Encoder
+}
+
+// NewJSONReporter creates a JSONReporter writing to w.
+func NewJSONReporter(w io.Writer) *JSONReporter {
+	return &JSONReporter{enc: json.NewEncoder(w)}
+}
+
+// Report writes one report object, with Event "report".
+func (r *JSONReporter) Report(rep Report) {
+	r.encode(struct {
+		Event string
+		Report
+	}{"report", rep})
+}
+
+// Summary writes the summary object, with Event "summary".
+func (r *JSONReporter) Summary(s ClientStats) {
+	r.encode(struct {
+		Event string
+		ClientStats
+	}{"summary", s})
+}
+
+// RepeatSummary writes the repeat statistics object, with Event "repeat".
+func (r *JSONReporter) RepeatSummary(s RepeatStats) {
+	r.encode(struct {
+		Event string
+		RepeatStats
+	}{"repeat", s})
+}
+
+func (r *JSONReporter) encode(v any) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	_ = r.enc.Encode(v) // reporting is best effort
+}
+
+// NopReporter discards all reports.
+type NopReporter struct{}
+
+// Report does nothing.
+func (NopReporter) Report(Report) {}
+
+// Summary does nothing.
+func (NopReporter) Summary(ClientStats) {}
+
+// ReportFunc adapts a function to a Reporter receiving only the reports.
+type ReportFunc func(Report)
+
+// Report calls f.
+func (f ReportFunc) Report(r Report) { f(r) }
+
+// Summary does nothing.
+func (f ReportFunc) Summary(ClientStats) {}
+
+// multiReporter sends everything to all of its reporters.
+type multiReporter []Reporter
+
+func (m multiReporter) Report(r Report) {
+	for _, rep := range m {
+		rep.Report(r)
+	}
+}
+
+func (m multiReporter) Summary(s ClientStats) {
+	for _, rep := range m {
+		rep.Summary(s)
+	}
+}
+
+func (m multiReporter) RepeatSummary(s RepeatStats) {
+	for _, rep := range m {
+		if r, ok := rep.(RepeatReporter); ok {
+			r.RepeatSummary(s)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package goben
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is one test variant of a scenario file.
+type Scenario struct {
+	Name    string
+	options []scenarioOption
+}
+
+// scenarioOption is a flag name and its YAML value, or the direction pseudo option.
+type scenarioOption struct {
+	name  string
+	value *yaml.Node
+}
+
+// ScenarioResult holds the outcome of one scenario.
+type ScenarioResult struct {
+	Name  string
+	Stats ClientStats
+	Err   error // nil if the scenario ran
+}
+
+// options a scenario cannot change: all scenarios run once against the same hosts
+var scenarioFixed = map[string]bool{
+	"hosts": true, "listeners": true, "config": true, "dumpConfig": true, "scenarios": true,
+	"repeat": true, "repeatPause": true,
+}
+
+const optionDirection = "direction" // both, send or receive
+
+// parseScenarios parses a scenario file: a list of scenarios, each a mapping
+// of flag names to values with an optional name, and/or a matrix mapping
+// flag names to lists of values. With both, every scenario runs with every
+// combination of the matrix.
+func parseScenarios(data []byte, flagset *pflag.FlagSet) ([]Scenario, error) {
+	var file struct {
+		Scenarios []yaml.Node
+		Matrix    yaml.Node
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	var scenarios []Scenario
+	for i, node := range file.Scenarios {
+		s, err := parseScenario(&node, flagset)
+		if err != nil {
+			return nil, err
+		}
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("scenario-%d", i+1)
+		}
+		scenarios = append(scenarios, s)
+	}
+
+	if file.Matrix.Kind == 0 {
+		if len(scenarios) == 0 {
+			return nil, fmt.Errorf("no scenarios and no matrix")
+		}
+		return scenarios, nil
+	}
+	if len(scenarios) == 0 {
+		scenarios = []Scenario{{}} // matrix only
+	}
+	return expandMatrix(scenarios, &file.Matrix, flagset)
+}
+
+func parseScenario(node *yaml.Node, flagset *pflag.FlagSet) (Scenario, error) {
+	var s Scenario
+	if node.Kind != yaml.MappingNode {
+		return s, fmt.Errorf("line %d: expected a scenario mapping of option names to values", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Value == "name" {
+			s.Name = value.Value
+			continue
+		}
+		if err := checkScenarioOption(key, flagset); err != nil {
+			return s, err
+		}
+		s.options = append(s.options, scenarioOption{name: key.Value, value: value})
+	}
+	return s, nil
+}
+
+func checkScenarioOption(key *yaml.Node, flagset *pflag.FlagSet) error {
+	if key.Value == optionDirection {
+		return nil
+	}
+	if flagset.Lookup(key.Value) == nil {
+		return fmt.Errorf("line %d: unknown option: %q", key.Line, key.Value)
+	}
+	if scenarioFixed[key.Value] {
+		return fmt.Errorf("line %d: option %q is the same for all scenarios", key.Line, key.Value)
+	}
+	return nil
+}
+
+// expandMatrix combines every scenario with every combination of the matrix values,
+// naming them after the combination, e.g. "tls-8 connections=8,udp=false".
+func expandMatrix(scenarios []Scenario, matrix *yaml.Node, flagset *pflag.FlagSet) ([]Scenario, error) {
+	if matrix.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("line %d: expected a matrix mapping of option names to lists of values", matrix.Line)
+	}
+	for i := 0; i+1 < len(matrix.Content); i += 2 {
+		key, values := matrix.Content[i], matrix.Content[i+1]
+		if err := checkScenarioOption(key, flagset); err != nil {
+			return nil, err
+		}
+		if values.Kind != yaml.SequenceNode || len(values.Content) == 0 {
+			return nil, fmt.Errorf("line %d: matrix %s: expected a list of values", values.Line, key.Value)
+		}
+		expanded := make([]Scenario, 0, len(scenarios)*len(values.Content))
+		for _, s := range scenarios {
+			for _, v := range values.Content {
+				name := key.Value + "=" + v.Value
+				if s.Name != "" {
+					name = s.Name + matrixSep(s.Name) + name
+				}
+				expanded = append(expanded, Scenario{
+					Name:    name,
+					options: append(s.options[:len(s.options):len(s.options)], scenarioOption{name: key.Value, value: v}),
+				})
+			}
+		}
+		scenarios = expanded
+	}
+	return scenarios, nil
+}
+
+// matrixSep separates the matrix values in scenario names.
+func matrixSep(name string) string {
+	if strings.Contains(name, "=") {
+		return ","
+	}
+	return " "
+}
+
+// scenarioConfig creates the config of a scenario: the options of app, as
+// set in flagset, with the scenario options on top.
+func (app *Config) scenarioConfig(flagset *pflag.FlagSet, s Scenario) (*Config, error) {
+	sc := &Config{}
+	scFlags := pflag.NewFlagSet("", pflag.ContinueOnError)
+	sc.AssignFlags(scFlags)
+
+	var errCopy error
+	flagset.VisitAll(func(f *pflag.Flag) {
+		if !f.Changed || errCopy != nil {
+			return
+		}
+		if slice, isSlice := f.Value.(pflag.SliceValue); isSlice {
+			errCopy = scFlags.Lookup(f.Name).Value.(pflag.SliceValue).Replace(slice.GetSlice())
+			return
+		}
+		errCopy = scFlags.Set(f.Name, f.Value.String())
+	})
+	if errCopy != nil {
+		return nil, errCopy
+	}
+
+	for _, o := range s.options {
+		var err error
+		if o.name == optionDirection {
+			err = setDirection(sc, o.value.Value)
+		} else {
+			err = setOption(scFlags, scFlags.Lookup(o.name), o.value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("scenario %s: line %d: %s: %w", s.Name, o.value.Line, o.name, err)
+		}
+	}
+
+	sc.Opt.Table = app.Opt.Table
+	sc.HostOverrides = app.HostOverrides
+	sc.logger = app.logger
+	sc.output = app.output
+	sc.reporter = app.reporter
+	sc.onReport = app.onReport
+	sc.exportPrefix = scenarioFilename(s.Name) + "-"
+	return sc, nil
+}
+
+// setDirection sets the traffic direction, as seen by the client.
+func setDirection(app *Config, direction string) error {
+	switch strings.ToLower(direction) {
+	case "both":
+		app.PassiveClient, app.Opt.PassiveServer = false, false
+	case "send":
+		app.PassiveClient, app.Opt.PassiveServer = false, true
+	case "receive":
+		app.PassiveClient, app.Opt.PassiveServer = true, false
+	default:
+		return fmt.Errorf("bad direction: %q (expected both, send or receive)", direction)
+	}
+	return nil
+}
+
+var reFilenameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func scenarioFilename(name string) string {
+	return reFilenameUnsafe.ReplaceAllString(name, "_")
+}
+
+// exportFilename prefixes the base name of an export file for scenarios.
+func (app *Config) exportFilename(filename string) string {
+	if app.exportPrefix == "" || filename == "" {
+		return filename
+	}
+	dir, base := filepath.Split(filename)
+	return filepath.Join(dir, app.exportPrefix+base)
+}
+
+// RunScenarios runs the scenarios of the --scenarios file one after the
+// other against the hosts of app, then logs a table comparing them.
+// flagset must hold the flags of app, see AssignFlags.
+// A scenario that fails records its error and the next one runs.
+func RunScenarios(ctx context.Context, app *Config, flagset *pflag.FlagSet) ([]ScenarioResult, error) {
+	data, errRead := os.ReadFile(app.Scenarios)
+	if errRead != nil {
+		return nil, fmt.Errorf("scenarios: %w", errRead)
+	}
+	scenarios, errParse := parseScenarios(data, flagset)
+	if errParse != nil {
+		return nil, fmt.Errorf("scenarios: %s: %w", app.Scenarios, errParse)
+	}
+
+	if app.Repeat > 1 {
+		return nil, fmt.Errorf("scenarios: --repeat is not supported with --scenarios")
+	}
+	if _, errUnit := lookupRateUnit(app.Unit); errUnit != nil {
+		return nil, fmt.Errorf("scenarios: %w", errUnit)
+	}
+
+	// catch option errors before running anything
+	configs := make([]*Config, len(scenarios))
+	for i, s := range scenarios {
+		sc, err := app.scenarioConfig(flagset, s)
+		if err != nil {
+			return nil, fmt.Errorf("scenarios: %s: %w", app.Scenarios, err)
+		}
+		configs[i] = sc
+	}
+
+	ctx = withHooks(ctx, app.hooks())
+
+	var results []ScenarioResult
+	for i, s := range scenarios {
+		if ctx.Err() != nil {
+			break
+		}
+		infof(ctx, "scenario %d/%d: %s", i+1, len(scenarios), s.Name)
+		stats, err := Open(ctx, configs[i])
+		if err != nil {
+			warnf(ctx, "scenario %d/%d: %s: %v", i+1, len(scenarios), s.Name, err)
+		}
+		results = append(results, ScenarioResult{Name: s.Name, Stats: stats, Err: err})
+	}
+
+	var table strings.Builder
+	if errTable := WriteScenarioTable(&table, results, app.Unit); errTable != nil {
+		return results, fmt.Errorf("scenarios: %w", errTable)
+	}
+	for line := range strings.Lines(table.String()) {
+		summaryf(ctx, "%s", strings.TrimSuffix(line, "\n"))
+	}
+
+	return results, nil
+}
+
+// WriteScenarioTable writes a table comparing the results of scenarios.
+// Rates are shown in unit, as in --unit.
+func WriteScenarioTable(w io.Writer, results []ScenarioResult, unit string) error {
+	u, errUnit := lookupRateUnit(unit)
+	if errUnit != nil {
+		return errUnit
+	}
+	var peak float64
+	for _, r := range results {
+		peak = max(peak, r.Stats.ReadMbps, r.Stats.WriteMbps)
+	}
+	u = u.forValue(peak)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "SCENARIO\tCONNS\tSENT\tRECEIVED\tSEND %s\tRECV %s\tERRORS\n", u.name, u.name)
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(tw, "%s\t\t\t\t\t\t%v\n", r.Name, r.Err)
+			continue
+		}
+		var conns, errs int
+		for _, h := range r.Stats.Hosts {
+			conns += len(h.Connections)
+			errs += len(h.Errors)
+			for _, c := range h.Connections {
+				errs += len(c.Errors)
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%.2f\t%.2f\t%d\n", r.Name, conns,
+			formatBytes(r.Stats.WriteBytes), formatBytes(r.Stats.ReadBytes),
+			u.scale(r.Stats.WriteMbps), u.scale(r.Stats.ReadMbps), errs)
+	}
+	return tw.Flush()
+}
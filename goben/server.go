@@ -8,7 +8,6 @@ import (
 	"encoding/gob"
 	"errors"
 	"fmt"
-	"log"
 	"net"
 	"os"
 	"sync"
@@ -23,17 +22,19 @@ func Serve(ctx context.Context, app *Config, wg *sync.WaitGroup) bool {
 		return false
 	}
 
+	ctx = withHooks(ctx, app.hooks())
+
 	// support falling back to TCP mode
 	if app.TLS && !fileExists(app.TLSKey) {
-		log.Printf("key file not found: %s - disabling TLS", app.TLSKey)
+		warnf(ctx, "key file not found: %s - disabling TLS", app.TLSKey)
 		app.TLS = false
 	}
 	if app.TLS && !fileExists(app.TLSCert) {
-		log.Printf("cert file not found: %s - disabling TLS", app.TLSCert)
+		warnf(ctx, "cert file not found: %s - disabling TLS", app.TLSCert)
 		app.TLS = false
 	}
 	if app.TLS && !fileExists(app.TLSCA) {
-		log.Printf("CA file not found: %s - disabling TLS", app.TLSCA)
+		warnf(ctx, "CA file not found: %s - disabling TLS", app.TLSCA)
 		app.TLS = false
 	}
 
@@ -54,7 +55,7 @@ func Serve(ctx context.Context, app *Config, wg *sync.WaitGroup) bool {
 
 	// if no listeners were successful, return that the socket is not listening
 	if successfulListeners == 0 {
-		log.Print("serve: no listeners successful")
+		errorf(ctx, "serve: no listeners successful")
 		return false
 	}
 
@@ -71,55 +72,63 @@ func listenTCP(ctx context.Context, app *Config, wg *sync.WaitGroup, h string) b
 
 	// first try TLS
 	if app.TLS {
-		log.Printf("listenTCP: spawning TLS listener: %s", h)
-		listener, errTLS := listenTLS(app, h)
+		debugf(ctx, "listenTCP: spawning TLS listener: %s", h)
+		listener, errTLS := listenTLS(ctx, app, h)
 		if errTLS == nil {
-			spawnAcceptLoopTCP(ctx, wg, listener, true)
+			app.addrs = append(app.addrs, listener.Addr())
+			spawnAcceptLoopTCP(ctx, app, wg, listener, true)
 			return true
 		}
-		log.Printf("listenTLS: %v", errTLS)
+		warnf(ctx, "listenTLS: %v", errTLS)
 		// TLS failed, try plain TCP if enabled
 		if !app.TCP {
-			log.Print("listenTCP: TLS failed and TCP disabled")
+			warnf(ctx, "listenTCP: TLS failed and TCP disabled")
 			return false
 		}
 	} else {
-		log.Print("listenTCP: TLS disabled")
+		debugf(ctx, "listenTCP: TLS disabled")
 	}
 
 	// only use TCP if explicitly enabled
 	if app.TCP {
 		if app.TLS {
-			log.Printf("listenTCP: falling back to TCP and spawning TCP listener: %s", h)
+			debugf(ctx, "listenTCP: falling back to TCP and spawning TCP listener: %s", h)
 		} else {
-			log.Printf("listenTCP: spawning TCP listener: %s", h)
+			debugf(ctx, "listenTCP: spawning TCP listener: %s", h)
 		}
-		listener, errListen := net.Listen("tcp", h)
+		listener, errListen := listenConfig(app).Listen(ctx, "tcp", h)
 		if errListen != nil {
-			log.Printf("listenTCP: TLS=%v %s: %v", app.TLS, h, errListen)
+			warnf(ctx, "listenTCP: TLS=%v %s: %v", app.TLS, h, errListen)
 			return false
 		}
-		spawnAcceptLoopTCP(ctx, wg, listener, false)
+		app.addrs = append(app.addrs, listener.Addr())
+		spawnAcceptLoopTCP(ctx, app, wg, listener, false)
 		return true
 	}
 
-	log.Print("listenTCP: TCP disabled")
+	debugf(ctx, "listenTCP: TCP disabled")
 
 	return false
 }
 
-func spawnAcceptLoopTCP(ctx context.Context, wg *sync.WaitGroup, listener net.Listener, isTLS bool) {
+func spawnAcceptLoopTCP(ctx context.Context, app *Config, wg *sync.WaitGroup, listener net.Listener, isTLS bool) {
 	wg.Add(1)
-	go handleTCP(ctx, wg, listener, isTLS)
+	go handleTCP(ctx, app, wg, listener, isTLS)
+}
+
+// listenConfig applies --bindDevice and --tos to server sockets.
+// Accepted TCP connections inherit the TOS, unless the client requests its own.
+func listenConfig(app *Config) *net.ListenConfig {
+	return &net.ListenConfig{Control: socketControl(app.Opt.TOS, app.BindDevice)}
 }
 
-func listenTLS(app *Config, h string) (net.Listener, error) {
-	log.Printf("reading cert and key from %s %s", app.TLSCert, app.TLSKey)
+func listenTLS(ctx context.Context, app *Config, h string) (net.Listener, error) {
+	debugf(ctx, "reading cert and key from %s %s", app.TLSCert, app.TLSKey)
 
 	// load the server cert
 	cert, errCert := tls.LoadX509KeyPair(app.TLSCert, app.TLSKey)
 	if errCert != nil {
-		log.Printf("listenTLS: failure loading TLS key pair: %v", errCert)
+		warnf(ctx, "listenTLS: failure loading TLS key pair: %v", errCert)
 		app.TLS = false // disable TLS
 		return nil, errCert
 	}
@@ -127,7 +136,7 @@ func listenTLS(app *Config, h string) (net.Listener, error) {
 	// load client CA cert
 	caCert, err := os.ReadFile(app.TLSCA)
 	if err != nil {
-		log.Printf("listenTLS: failure reading CA cert: %v", err)
+		warnf(ctx, "listenTLS: failure reading CA cert: %v", err)
 		return nil, err
 	}
 
@@ -147,30 +156,40 @@ func listenTLS(app *Config, h string) (net.Listener, error) {
 		ClientCAs:    caCertPool,
 		ClientAuth:   clientAuth,
 	}
-	listener, errListen := tls.Listen("tcp", h, config)
-	return listener, errListen
+	listener, errListen := listenConfig(app).Listen(ctx, "tcp", h)
+	if errListen != nil {
+		return nil, errListen
+	}
+	return tls.NewListener(listener, config), nil
 }
 
 func listenUDP(ctx context.Context, app *Config, wg *sync.WaitGroup, h string) bool {
 	if app.UDP {
-		log.Printf("serve: spawning UDP listener: %s", h)
+		debugf(ctx, "serve: spawning UDP listener: %s", h)
 
 		udpAddr, errAddr := net.ResolveUDPAddr("udp", h)
 		if errAddr != nil {
-			log.Printf("listenUDP: bad address: %s: %v", h, errAddr)
+			warnf(ctx, "listenUDP: bad address: %s: %v", h, errAddr)
 			return false
 		}
 
-		conn, errListen := net.ListenUDP("udp", udpAddr)
+		pc, errListen := listenConfig(app).ListenPacket(ctx, "udp", udpAddr.String())
 		if errListen != nil {
-			log.Printf("net.ListenUDP: %s: %v", h, errListen)
+			warnf(ctx, "net.ListenUDP: %s: %v", h, errListen)
+			return false
+		}
+		conn, isUDP := pc.(*net.UDPConn)
+		if !isUDP {
+			pc.Close()
+			warnf(ctx, "listenUDP: %s: unexpected packet conn type: %T", h, pc)
 			return false
 		}
+		app.addrs = append(app.addrs, conn.LocalAddr())
 
 		wg.Add(1)
 		go handleUDP(ctx, app, wg, conn)
 	} else {
-		log.Print("listenUDP: UDP disabled")
+		debugf(ctx, "listenUDP: UDP disabled")
 		return false
 	}
 	return true
@@ -197,7 +216,7 @@ LOOP:
 	return host + port
 }
 
-func handleTCP(ctx context.Context, wg *sync.WaitGroup, listener net.Listener, isTLS bool) {
+func handleTCP(ctx context.Context, app *Config, wg *sync.WaitGroup, listener net.Listener, isTLS bool) {
 	defer wg.Done()
 
 	// Use a derived context so the closer goroutine exits when handleTCP returns,
@@ -222,7 +241,7 @@ func handleTCP(ctx context.Context, wg *sync.WaitGroup, listener net.Listener, i
 		if errAccept != nil {
 			select {
 			case <-ctx.Done():
-				log.Printf("handleTCP: shutdown requested")
+				debugf(ctx, "handleTCP: shutdown requested")
 				return
 			default:
 			}
@@ -236,25 +255,41 @@ func handleTCP(ctx context.Context, wg *sync.WaitGroup, listener net.Listener, i
 				retryDelay *= 2
 			}
 			if retryDelay > time.Second {
-				log.Printf("handle: accept: %v", errAccept)
+				warnf(ctx, "handle: accept: %v", errAccept)
 				return
 			}
-			log.Printf("handle: accept temporary error, retrying in %v: %v", retryDelay, errAccept)
+			warnf(ctx, "handle: accept temporary error, retrying in %v: %v", retryDelay, errAccept)
 			time.Sleep(retryDelay)
 			continue
 		}
 		retryDelay = 0
-		go handleConnection(ctx, conn, id, 0, isTLS, &aggReader, &aggWriter)
+		go handleConnection(ctx, app, conn, id, 0, isTLS, &aggReader, &aggWriter)
 		id++
 	}
 }
 
+// udpIdleTimeout finishes UDP clients without a total duration,
+// once no datagram arrives for this long, e.g. when the last ones of
+// a byte-bounded test are lost.
+const udpIdleTimeout = 5 * time.Second
+
 type udpInfo struct {
-	remote *net.UDPAddr
-	opt    Options
-	acc    *account
-	start  time.Time
-	id     int
+	remote   *net.UDPAddr
+	opt      Options
+	acc      *account
+	start    time.Time
+	lastSeen time.Time // last datagram
+	id       int
+	verify   *verifier // nil unless --verify
+	done     bool      // ignore later datagrams
+}
+
+// deadline returns when the server stops waiting for datagrams of the client.
+func (info *udpInfo) deadline() time.Time {
+	if info.opt.TotalDuration > 0 {
+		return info.start.Add(info.opt.TotalDuration)
+	}
+	return info.lastSeen.Add(udpIdleTimeout)
 }
 
 func handleUDP(ctx context.Context, app *Config, wg *sync.WaitGroup, conn *net.UDPConn) {
@@ -285,14 +320,18 @@ func handleUDP(ctx context.Context, app *Config, wg *sync.WaitGroup, conn *net.U
 		if errRead != nil {
 			select {
 			case <-ctx.Done():
-				log.Printf("handleUDP: shutdown requested")
+				debugf(ctx, "handleUDP: shutdown requested")
 				return
 			default:
+				if errors.Is(errRead, os.ErrDeadlineExceeded) {
+					expireUDP(ctx, conn, tab, &aggReader)
+					continue
+				}
 				if src == nil {
-					log.Printf("handleUDP: read nil src: error: %v", errRead)
+					warnf(ctx, "handleUDP: read nil src: error: %v", errRead)
 					continue
 				}
-				log.Printf("handleUDP: read error: %v", errRead)
+				warnf(ctx, "handleUDP: read error: %v", errRead)
 				continue
 			}
 		}
@@ -302,142 +341,310 @@ func handleUDP(ctx context.Context, app *Config, wg *sync.WaitGroup, conn *net.U
 		var found bool
 		info, found = tab[src.String()]
 		if !found {
-			log.Printf("handleUDP: incoming: %v", src)
+			infof(ctx, "handleUDP: incoming: %v", src)
 
+			now := time.Now()
 			info = &udpInfo{
-				remote: src,
-				acc:    &account{},
-				start:  time.Now(),
-				id:     idCount,
+				remote:   src,
+				start:    now,
+				lastSeen: now,
+				id:       idCount,
 			}
 			idCount++
-			info.acc.prevTime = info.start
 			tab[src.String()] = info
 
 			dec := gob.NewDecoder(bytes.NewBuffer(buf[:n]))
 			if errOpt := dec.Decode(&info.opt); errOpt != nil {
-				log.Printf("handleUDP: options failure: %v", errOpt)
+				warnf(ctx, "handleUDP: options failure: %v", errOpt)
+				info.done = true
 				continue
 			}
-			log.Printf("handleUDP: options received: %v", info.opt)
+			debugf(ctx, "handleUDP: options received: %v", info.opt)
+
+			info.acc = newAccount(info.start, info.opt.Omit)
+			info.acc.hooks = hooksFrom(ctx)
+
+			// confirm sequence numbers before the first datagram carrying them
+			if info.opt.SeqNumbers {
+				a := newAck()
+				a.Table[ackSeqNumbers] = "true"
+				if errAck := ackSend(ctx, true, udpPeer{conn: conn, addr: src}, a); errAck != nil {
+					warnf(ctx, "handleUDP: sending ack: %v", errAck)
+				}
+			}
+
+			if info.opt.Verify {
+				info.verify = newReceiveVerifier(info.opt, streamUpload, true)
+			}
 
 			if !info.opt.PassiveServer {
 				opt := info.opt // copy for goroutine
 				go serverWriterTo(ctx, conn, opt, src, info.acc, info.id, 0, &aggWriter)
 			}
 
+			// the client may stop sending at its deadline, without another datagram
+			expireUDP(ctx, conn, tab, &aggReader)
+
+			continue
+		}
+
+		if info.done {
 			continue
 		}
 
 		connIndex := fmt.Sprintf("%d/%d", info.id, 0)
 
 		if errRead != nil {
-			log.Printf("handleUDP: %s read error: %s: %v", connIndex, src, errRead)
+			warnf(ctx, "handleUDP: %s read error: %s: %v", connIndex, src, errRead)
+			continue
+		}
+
+		if info.opt.TotalDuration > 0 && time.Since(info.start) > info.opt.TotalDuration {
+			infof(ctx, "handleUDP: total duration %s timer: %s", info.opt.TotalDuration, src)
+			info.finish(ctx, connIndex, &aggReader)
 			continue
 		}
 
-		if time.Since(info.start) > info.opt.TotalDuration {
-			log.Printf("handleUDP: total duration %s timer: %s", info.opt.TotalDuration, src)
-			info.acc.average(info.start, connIndex, "handleUDP", "rcv/s", &aggReader)
-			log.Printf("handleUDP: FIXME: remove idle udp entry from udp table")
+		info.lastSeen = time.Now()
+
+		// count no more than the byte/block limit, as limitCall does for TCP
+		p := buf[:n]
+		limit := info.opt.byteLimit(true)
+		if limit > 0 && int64(len(p)) > limit-info.acc.size {
+			p = p[:limit-info.acc.size]
+		}
+
+		if info.verify != nil {
+			info.verify.check(p)
+		}
+
+		info.acc.update(len(p), info.opt.ReportInterval, connIndex, "handleUDP", "rcv/s", nil, false)
+
+		if limit > 0 && info.acc.size >= limit {
+			infof(ctx, "handleUDP: transfer complete: %d bytes: %s", info.acc.size, src)
+			info.finish(ctx, connIndex, &aggReader)
+		}
+	}
+}
+
+// udpPeer writes datagrams to one UDP client.
+type udpPeer struct {
+	conn *net.UDPConn
+	addr net.Addr
+}
+
+func (p udpPeer) Write(b []byte) (int, error) {
+	return p.conn.WriteTo(b, p.addr)
+}
+
+// expireUDP finishes the UDP clients past their deadline, then sets the
+// read deadline of conn to the earliest one left, so that handleUDP wakes up
+// for it even when no more datagrams arrive.
+func expireUDP(ctx context.Context, conn *net.UDPConn, tab map[string]*udpInfo, agg *aggregate) {
+	now := time.Now()
+	var next time.Time
+	for _, info := range tab {
+		if info.done {
+			continue
+		}
+		deadline := info.deadline()
+		if !deadline.After(now) {
+			if info.opt.TotalDuration > 0 {
+				infof(ctx, "handleUDP: total duration %s timer: %s", info.opt.TotalDuration, info.remote)
+			} else {
+				infof(ctx, "handleUDP: idle %s timer: %s", udpIdleTimeout, info.remote)
+			}
+			info.finish(ctx, fmt.Sprintf("%d/%d", info.id, 0), agg)
 			continue
 		}
+		if next.IsZero() || deadline.Before(next) {
+			next = deadline
+		}
+	}
+	if err := conn.SetReadDeadline(next); err != nil {
+		warnf(ctx, "handleUDP: set read deadline: %v", err)
+	}
+}
 
-		info.acc.update(n, info.opt.ReportInterval, connIndex, "handleUDP", "rcv/s", nil, false)
+// finish reports the results of a UDP client once, then ignores its later datagrams.
+func (info *udpInfo) finish(ctx context.Context, connIndex string, agg *aggregate) {
+	info.done = true
+	info.acc.average(connIndex, "handleUDP", "rcv/s", agg)
+	if info.verify != nil {
+		logIntegrity(ctx, "handleUDP", connIndex, info.verify.result)
+		agg.addIntegrity(info.verify.result)
 	}
+	debugf(ctx, "handleUDP: FIXME: remove idle udp entry from udp table")
 }
 
-func handleConnection(ctx context.Context, conn net.Conn, c, connections int, isTLS bool, aggReader, aggWriter *aggregate) {
+func handleConnection(ctx context.Context, app *Config, conn net.Conn, c, connections int, isTLS bool, aggReader, aggWriter *aggregate) {
 	// Use sync.Once so conn.Close() is safe to call explicitly before returning
 	// (to unblock goroutines) as well as via defer for early-exit paths.
 	var closeOnce sync.Once
 	closeConn := func() { closeOnce.Do(func() { conn.Close() }) }
 	defer closeConn()
 
-	log.Printf("handleConnection: incoming: %s %v", protoLabel(isTLS), conn.RemoteAddr())
+	infof(ctx, "handleConnection: incoming: %s %v", protoLabel(isTLS), conn.RemoteAddr())
 
 	// ensure the TLS handshake if this is a TLS connection
 	tlscon, ok := conn.(*tls.Conn)
 	if ok {
 		err := tlscon.Handshake()
 		if err != nil {
-			log.Printf("server: handshake failed: %v", err)
+			warnf(ctx, "server: handshake failed: %v", err)
 			return
 		}
 		state := tlscon.ConnectionState()
-		log.Println("Server: client public key is:")
+		debugf(ctx, "Server: client public key is:")
 		for _, v := range state.PeerCertificates {
-			log.Print("- Subject: ", v.Subject)
-			log.Print("  Issuer: ", v.Issuer)
-			log.Print("  Expiration: ", v.NotAfter)
+			debugf(ctx, "- Subject: %v", v.Subject)
+			debugf(ctx, "  Issuer: %v", v.Issuer)
+			debugf(ctx, "  Expiration: %v", v.NotAfter)
 		}
 	} else {
-		log.Print("handleConnection: not TLS")
+		debugf(ctx, "handleConnection: not TLS")
 	}
 
 	// receive options
 	var opt Options
-	dec := gob.NewDecoder(conn)
+	dec := gob.NewDecoder(byteReader{conn})
 	if errOpt := dec.Decode(&opt); errOpt != nil {
 		if isTLS {
-			log.Printf("handleConnection: options failure: %v", errOpt)
+			warnf(ctx, "handleConnection: options failure: %v", errOpt)
 		} else {
-			log.Printf("handleConnection: options failure - it might be client attempting our (disabled) TLS first: %v", errOpt)
+			warnf(ctx, "handleConnection: options failure - it might be client attempting our (disabled) TLS first: %v", errOpt)
 		}
 		return
 	}
-	log.Printf("handleConnection: options received: %v", opt)
+	debugf(ctx, "handleConnection: options received: %v", opt)
 
 	if clientVersion, ok := opt.Table["clientVersion"]; ok {
-		log.Printf("handleConnection: clientVersion=%s", clientVersion)
+		debugf(ctx, "handleConnection: clientVersion=%s", clientVersion)
+	}
+
+	if opt.TOS != 0 {
+		if errTOS := setConnTOS(conn, opt.TOS); errTOS != nil {
+			warnf(ctx, "handleConnection: TOS=0x%02x: %v", opt.TOS, errTOS)
+		}
 	}
 
-	// send ack
+	// send ack, confirming the start message to clients that request it
 	a := newAck()
-	if errAck := ackSend(false, conn, a); errAck != nil {
-		log.Printf("handleConnection: sending ack: %v", errAck)
+	if opt.SyncStart {
+		a.Table[ackSyncStart] = "true"
+	}
+	if errAck := ackSend(ctx, false, conn, a); errAck != nil {
+		warnf(ctx, "handleConnection: sending ack: %v", errAck)
 		return
 	}
 
+	// wait until the client releases all of its connections together
+	if opt.SyncStart {
+		stopCloser := context.AfterFunc(ctx, closeConn)
+		errStart := startRecv(ctx, conn)
+		stopCloser()
+		if errStart != nil {
+			warnf(ctx, "handleConnection: receiving start: %v", errStart)
+			return
+		}
+	}
+
+	tcpInfo := tcpInfoSampler(conn)
+
 	var connWg sync.WaitGroup
 
 	connWg.Go(func() {
-		serverReader(ctx, conn, opt, c, connections, isTLS, aggReader)
+		serverReader(ctx, conn, opt, c, connections, isTLS, aggReader, tcpInfo, app.WriteDir)
 	})
 
 	if !opt.PassiveServer {
 		connWg.Go(func() {
-			serverWriter(ctx, conn, opt, c, connections, isTLS, aggWriter)
+			serverWriter(ctx, conn, opt, c, connections, isTLS, aggWriter, tcpInfo)
 		})
 	}
 
-	tickerPeriod := time.NewTimer(opt.TotalDuration)
+	// with a byte/block limit, the connection ends when both directions complete
+	var finished chan struct{}
+	if opt.hasLimit() {
+		finished = make(chan struct{})
+		go func() {
+			connWg.Wait()
+			close(finished)
+		}()
+	}
+
+	var timeout <-chan time.Time
+	if opt.TotalDuration > 0 {
+		tickerPeriod := time.NewTimer(opt.TotalDuration)
+		defer tickerPeriod.Stop()
+		timeout = tickerPeriod.C
+	}
 
 	select {
-	case <-tickerPeriod.C:
-		log.Printf("handleConnection: %v timer", opt.TotalDuration)
+	case <-timeout:
+		infof(ctx, "handleConnection: %v timer", opt.TotalDuration)
+	case <-finished:
+		infof(ctx, "handleConnection: transfer complete: %v", conn.RemoteAddr())
 	case <-ctx.Done():
-		log.Printf("handleConnection: received shutdown signal")
+		infof(ctx, "handleConnection: received shutdown signal")
 	}
 
-	tickerPeriod.Stop()
+	if tcpInfo != nil {
+		if ti, ok := tcpInfo(); ok {
+			infof(ctx, "handleConnection: %v retransmits: %d", conn.RemoteAddr(), ti.Retransmits)
+			aggWriter.addRetransmits(ti.Retransmits)
+		}
+	}
 
-	log.Printf("handleConnection: closing: %v", conn.RemoteAddr())
+	infof(ctx, "handleConnection: closing: %v", conn.RemoteAddr())
 	closeConn() // force reader/writer goroutines to unblock
 	connWg.Wait()
 }
 
-func serverReader(ctx context.Context, conn net.Conn, opt Options, c, connections int, isTLS bool, agg *aggregate) {
+func serverReader(ctx context.Context, conn net.Conn, opt Options, c, connections int, isTLS bool, agg *aggregate, tcpInfo tcpInfoFunc, writeDir string) {
 
-	log.Printf("serverReader: starting: %s %v", protoLabel(isTLS), conn.RemoteAddr())
+	debugf(ctx, "serverReader: starting: %s %v", protoLabel(isTLS), conn.RemoteAddr())
 
 	connIndex := fmt.Sprintf("%d/%d", c, connections)
 
 	buf := make([]byte, opt.TCPReadSize)
 
-	workLoop(ctx, connIndex, "serverReader", "rcv/s", conn.Read, buf, opt.ReportInterval, 0, nil, agg)
+	read := conn.Read
+
+	var v *verifier
+	if opt.Verify {
+		v = newReceiveVerifier(opt, streamUpload, false)
+		read = verifyRead(read, v)
+	}
+
+	var disk *diskStats
+	if writeDir != "" {
+		f, errOpen := openReceiveFile(writeDir, c, formatAddress(conn))
+		if errOpen != nil {
+			warnf(ctx, "serverReader: %s: %v", connIndex, errOpen)
+		} else {
+			defer f.Close()
+			disk = &diskStats{}
+			read = fileRecvCall(read, f, disk)
+		}
+	}
+
+	read = limitCall(read, opt.byteLimit(false))
+
+	workLoop(ctx, connIndex, "serverReader", "rcv/s", read, buf, opt.ReportInterval, opt.Omit, 0, nil, agg, tcpInfo)
+
+	if disk != nil {
+		reportDisk(ctx, "serverReader", connIndex, "write", *disk)
+		agg.addDisk(*disk)
+	}
+
+	if v != nil {
+		v.finish()
+		logIntegrity(ctx, "serverReader", connIndex, v.result)
+		agg.addIntegrity(v.result)
+	}
 
-	log.Printf("serverReader: exiting: %v", conn.RemoteAddr())
+	debugf(ctx, "serverReader: exiting: %v", conn.RemoteAddr())
 }
 
 func protoLabel(isTLS bool) string {
@@ -447,26 +654,39 @@ func protoLabel(isTLS bool) string {
 	return "TCP"
 }
 
-func serverWriter(ctx context.Context, conn net.Conn, opt Options, c, connections int, isTLS bool, agg *aggregate) {
+func serverWriter(ctx context.Context, conn net.Conn, opt Options, c, connections int, isTLS bool, agg *aggregate, tcpInfo tcpInfoFunc) {
 
-	log.Printf("serverWriter: starting: %s %v", protoLabel(isTLS), conn.RemoteAddr())
+	debugf(ctx, "serverWriter: starting: %s %v", protoLabel(isTLS), conn.RemoteAddr())
 
 	connIndex := fmt.Sprintf("%d/%d", c, connections)
 
-	buf := randBuf(opt.TCPWriteSize)
+	buf := payloadBuf(opt, opt.TCPWriteSize)
+
+	write := conn.Write
+	if opt.Verify {
+		write = verifyWrite(write, newVerifier(opt.Seed, streamDownload, false))
+	}
+	write = limitCall(write, opt.byteLimit(false))
 
-	workLoop(ctx, connIndex, "serverWriter", "snd/s", conn.Write, buf, opt.ReportInterval, opt.MaxSpeed, nil, agg)
+	workLoop(ctx, connIndex, "serverWriter", "snd/s", write, buf, opt.ReportInterval, opt.Omit, opt.MaxSpeed, nil, agg, tcpInfo)
 
-	log.Printf("serverWriter: exiting: %v", conn.RemoteAddr())
+	debugf(ctx, "serverWriter: exiting: %v", conn.RemoteAddr())
 }
 
 func serverWriterTo(ctx context.Context, conn *net.UDPConn, opt Options, dst net.Addr, acc *account, c, connections int, agg *aggregate) {
-	log.Printf("serverWriterTo: starting: UDP %v", dst)
+	debugf(ctx, "serverWriterTo: starting: UDP %v", dst)
+
+	// the UDP socket is shared by all clients, so the latest marking wins
+	if opt.TOS != 0 {
+		if errTOS := setConnTOS(conn, opt.TOS); errTOS != nil {
+			warnf(ctx, "serverWriterTo: TOS=0x%02x: %v", opt.TOS, errTOS)
+		}
+	}
 
 	start := acc.prevTime
 
 	udpWriteTo := func(b []byte) (int, error) {
-		if time.Since(start) > opt.TotalDuration {
+		if opt.TotalDuration > 0 && time.Since(start) > opt.TotalDuration {
 			return -1, fmt.Errorf("udpWriteTo: total duration %s timer", opt.TotalDuration)
 		}
 
@@ -475,9 +695,17 @@ func serverWriterTo(ctx context.Context, conn *net.UDPConn, opt Options, dst net
 
 	connIndex := fmt.Sprintf("%d/%d", c, connections)
 
-	buf := randBuf(opt.UDPWriteSize)
+	buf := payloadBuf(opt, opt.UDPWriteSize)
+
+	write := udpWriteTo
+	if opt.Verify {
+		write = verifyWrite(write, newVerifier(opt.Seed, streamDownload, true))
+	} else if opt.SeqNumbers {
+		write = seqWrite(write) // verified datagrams carry their own
+	}
+	write = limitCall(write, opt.byteLimit(true))
 
-	workLoop(ctx, connIndex, "serverWriterTo", "snd/s", udpWriteTo, buf, opt.ReportInterval, opt.MaxSpeed, nil, agg)
+	workLoop(ctx, connIndex, "serverWriterTo", "snd/s", write, buf, opt.ReportInterval, opt.Omit, opt.MaxSpeed, nil, agg, nil)
 
-	log.Printf("serverWriterTo: exiting: %v", dst)
+	debugf(ctx, "serverWriterTo: exiting: %v", dst)
 }
@@ -0,0 +1,77 @@
+package goben
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// rawConnOf returns the raw socket beneath conn, unwrapping TLS.
+func rawConnOf(conn net.Conn) (syscall.RawConn, error) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil, fmt.Errorf("unsupported connection type: %T", conn)
+	}
+	return sc.SyscallConn()
+}
+
+// setConnTOS marks traffic sent on conn with the given TOS byte.
+func setConnTOS(conn net.Conn, tos int) error {
+	raw, errRaw := rawConnOf(conn)
+	if errRaw != nil {
+		return fmt.Errorf("setConnTOS: %w", errRaw)
+	}
+	return setRawTOS(raw, tos)
+}
+
+// setRawTOS sets both IP_TOS and IPV6_TCLASS, since a socket may carry
+// IPv4 traffic (including v4-mapped addresses on IPv6 sockets) or IPv6 traffic.
+// Only fails if neither option could be set.
+func setRawTOS(raw syscall.RawConn, tos int) error {
+	var errTOS error
+	errControl := raw.Control(func(fd uintptr) {
+		errTOS = setsockoptTOS(fd, tos)
+	})
+	if errControl != nil {
+		return errControl
+	}
+	return errTOS
+}
+
+// setRawBindDevice binds a socket to a network interface (or VRF device).
+func setRawBindDevice(raw syscall.RawConn, device string) error {
+	var errBind error
+	errControl := raw.Control(func(fd uintptr) {
+		errBind = bindToDevice(fd, device)
+	})
+	if errControl != nil {
+		return errControl
+	}
+	return errBind
+}
+
+// socketControl returns a net.Dialer/net.ListenConfig Control function
+// binding new sockets to device and marking them with tos.
+// Returns nil when there is nothing to set.
+func socketControl(tos int, device string) func(network, address string, c syscall.RawConn) error {
+	if tos == 0 && device == "" {
+		return nil
+	}
+	return func(_, _ string, c syscall.RawConn) error {
+		if device != "" {
+			if err := setRawBindDevice(c, device); err != nil {
+				return fmt.Errorf("bind to device %s: %w", device, err)
+			}
+		}
+		if tos != 0 {
+			if err := setRawTOS(c, tos); err != nil {
+				return fmt.Errorf("set TOS 0x%02x: %w", tos, err)
+			}
+		}
+		return nil
+	}
+}
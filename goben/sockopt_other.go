@@ -0,0 +1,12 @@
+//go:build !unix
+
+package goben
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func setsockoptTOS(_ uintptr, _ int) error {
+	return fmt.Errorf("setsockoptTOS: not supported on %s", runtime.GOOS)
+}
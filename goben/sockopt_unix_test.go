@@ -0,0 +1,76 @@
+//go:build unix
+
+package goben
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func getsockoptTOS(t *testing.T, conn net.Conn, level, opt int) int {
+	t.Helper()
+	raw, errRaw := rawConnOf(conn)
+	if errRaw != nil {
+		t.Fatalf("raw conn: %v", errRaw)
+	}
+	var value int
+	var errOpt error
+	if err := raw.Control(func(fd uintptr) {
+		value, errOpt = unix.GetsockoptInt(int(fd), level, opt)
+	}); err != nil {
+		t.Fatalf("control: %v", err)
+	}
+	if errOpt != nil {
+		t.Fatalf("getsockopt: %v", errOpt)
+	}
+	return value
+}
+
+func TestSetConnTOS(t *testing.T) {
+	const tos = 46 << 2 // DSCP EF
+
+	for _, tc := range []struct {
+		network string
+		addr    string
+		level   int
+		opt     int
+	}{
+		{"tcp4", "127.0.0.1:0", unix.IPPROTO_IP, unix.IP_TOS},
+		{"tcp6", "[::1]:0", unix.IPPROTO_IPV6, unix.IPV6_TCLASS},
+	} {
+		listener, errListen := net.Listen(tc.network, tc.addr)
+		if errListen != nil {
+			t.Logf("%s: skipping: %v", tc.network, errListen)
+			continue
+		}
+
+		// client side: mark via dialer control
+		dialer := net.Dialer{Control: socketControl(tos, "")}
+		conn, errDial := dialer.Dial(tc.network, listener.Addr().String())
+		if errDial != nil {
+			listener.Close()
+			t.Fatalf("%s: dial: %v", tc.network, errDial)
+		}
+		if got := getsockoptTOS(t, conn, tc.level, tc.opt); got != tos {
+			t.Errorf("%s: dialer: tos=%d wanted=%d", tc.network, got, tos)
+		}
+
+		// server side: mark accepted connection
+		accepted, errAccept := listener.Accept()
+		if errAccept != nil {
+			t.Fatalf("%s: accept: %v", tc.network, errAccept)
+		}
+		if errTOS := setConnTOS(accepted, tos); errTOS != nil {
+			t.Errorf("%s: setConnTOS: %v", tc.network, errTOS)
+		}
+		if got := getsockoptTOS(t, accepted, tc.level, tc.opt); got != tos {
+			t.Errorf("%s: accepted: tos=%d wanted=%d", tc.network, got, tos)
+		}
+
+		accepted.Close()
+		conn.Close()
+		listener.Close()
+	}
+}
@@ -0,0 +1,115 @@
+package goben
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// HostStats records the results of all connections to one host.
+type HostStats struct {
+	Host        string // host:port
+	Connections []ConnStats
+	ReadMbps    float64 // sum of connection averages
+	WriteMbps   float64
+	ReadBytes   int64
+	WriteBytes  int64
+	Errors      []string // connections that could not be established
+}
+
+// ConnStats records the results of one client connection.
+type ConnStats struct {
+	Host        string // host:port
+	Index       int    // connection index for the host
+	Remote      string // remote address
+	Proto       string // TCP, TLS or UDP
+	Elapsed     time.Duration
+	Read        DirStats
+	Write       DirStats
+	Retransmits uint64   // TCP retransmitted segments (linux only)
+	Errors      []string // errors encountered by the connection
+}
+
+// DirStats records one direction of a connection.
+type DirStats struct {
+	Mbps      float64 // average, excluding the warm-up period
+	Cps       int64   // average calls per second
+	Bytes     int64
+	Calls     int64
+	Intervals ChartData // one value per report interval
+}
+
+// loopResult is the outcome of a workLoop.
+type loopResult struct {
+	mbps  float64
+	cps   int64
+	bytes int64
+	calls int64
+	err   error // error that ended the loop, nil for a normal end
+	end   time.Time
+}
+
+func (r loopResult) dirStats(intervals ChartData) DirStats {
+	return DirStats{
+		Mbps:      r.mbps,
+		Cps:       r.cps,
+		Bytes:     r.bytes,
+		Calls:     r.calls,
+		Intervals: intervals,
+	}
+}
+
+// loopError filters the errors that normally end a connection:
+// the local close at the end of the test, and the peer's close.
+func loopError(err error) error {
+	if err == nil || errors.Is(err, errLimitReached) || errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// hostStats assembles per-host results from the run's connections.
+func (run *clientRun) hostStats() []HostStats {
+	hosts := make([]HostStats, len(run.hosts))
+	for i, h := range run.hosts {
+		hs := HostStats{Host: h.host, Errors: h.errors}
+		for _, cs := range h.conns {
+			hs.Connections = append(hs.Connections, *cs)
+			hs.ReadMbps += cs.Read.Mbps
+			hs.WriteMbps += cs.Write.Mbps
+			hs.ReadBytes += cs.Read.Bytes
+			hs.WriteBytes += cs.Write.Bytes
+		}
+		hosts[i] = hs
+	}
+	return hosts
+}
+
+// hostRun holds the connections of one host during a run.
+type hostRun struct {
+	host   string
+	conns  []*ConnStats
+	errors []string
+}
+
+// newConn registers a connection to the h-th host.
+func (run *clientRun) newConn(h, c int, conn net.Conn, isTLS bool) *ConnStats {
+	proto := protoLabel(isTLS)
+	if run.app.UDP {
+		proto = "UDP"
+	}
+	cs := &ConnStats{
+		Host:   run.hosts[h].host,
+		Index:  c,
+		Remote: conn.RemoteAddr().String(),
+		Proto:  proto,
+	}
+	run.hosts[h].conns = append(run.hosts[h].conns, cs)
+	return cs
+}
+
+// hostError records a connection to the h-th host that could not be established.
+func (run *clientRun) hostError(h int, err error) {
+	run.hosts[h].errors = append(run.hosts[h].errors, err.Error())
+}
@@ -0,0 +1,101 @@
+package goben
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+)
+
+// WriteSummaryTable writes a table of the results of a client run:
+// one row per connection, then per-host and grand totals, then errors.
+// Rates are shown in unit, as in --unit, auto picks one unit for the table.
+func WriteSummaryTable(w io.Writer, s ClientStats, unit string) error {
+	u, errUnit := lookupRateUnit(unit)
+	if errUnit != nil {
+		return errUnit
+	}
+	u = u.forValue(maxRate(s))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "HOST\tCONN\tPROTO\tSENT\tRECEIVED\tSEND %s (min-max)\tRECV %s (min-max)\tERRORS\n", u.name, u.name)
+
+	var totalSent, totalRecv int64
+	var totalSend, totalRead float64
+	var totalErrors int
+	var errs []string
+
+	for _, h := range s.Hosts {
+		hostErrors := len(h.Errors)
+		for _, e := range h.Errors {
+			errs = append(errs, fmt.Sprintf("%s: %s", h.Host, e))
+		}
+		for _, c := range h.Connections {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%d\n", h.Host, c.Index, c.Proto,
+				formatBytes(c.Write.Bytes), formatBytes(c.Read.Bytes),
+				formatRateRange(c.Write, u), formatRateRange(c.Read, u), len(c.Errors))
+			hostErrors += len(c.Errors)
+			for _, e := range c.Errors {
+				errs = append(errs, fmt.Sprintf("%s %d: %s", h.Host, c.Index, e))
+			}
+		}
+		if len(h.Connections) > 1 {
+			fmt.Fprintf(tw, "%s\ttotal\t\t%s\t%s\t%.2f\t%.2f\t%d\n", h.Host,
+				formatBytes(h.WriteBytes), formatBytes(h.ReadBytes), u.scale(h.WriteMbps), u.scale(h.ReadMbps), hostErrors)
+		}
+		totalSent += h.WriteBytes
+		totalRecv += h.ReadBytes
+		totalSend += h.WriteMbps
+		totalRead += h.ReadMbps
+		totalErrors += hostErrors
+	}
+
+	if len(s.Hosts) > 1 {
+		fmt.Fprintf(tw, "TOTAL\t\t\t%s\t%s\t%.2f\t%.2f\t%d\n",
+			formatBytes(totalSent), formatBytes(totalRecv), u.scale(totalSend), u.scale(totalRead), totalErrors)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		if _, err := fmt.Fprintf(w, "errors:\n  %s\n", strings.Join(errs, "\n  ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxRate returns the highest host total rate, to pick an auto unit.
+func maxRate(s ClientStats) float64 {
+	var m float64
+	for _, h := range s.Hosts {
+		m = max(m, h.ReadMbps, h.WriteMbps)
+	}
+	return m
+}
+
+// formatRateRange formats the average rate and the range of interval rates in unit u.
+func formatRateRange(d DirStats, u rateUnit) string {
+	if len(d.Intervals.YValues) == 0 {
+		return fmt.Sprintf("%.2f", u.scale(d.Mbps))
+	}
+	return fmt.Sprintf("%.2f (%.2f-%.2f)", u.scale(d.Mbps), u.scale(slices.Min(d.Intervals.YValues)), u.scale(slices.Max(d.Intervals.YValues)))
+}
+
+// formatBytes formats a byte count with a decimal unit.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,39 @@
+package goben
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// TCPInfo records kernel TCP statistics sampled from a connection socket.
+type TCPInfo struct {
+	RTT         time.Duration // smoothed round-trip time
+	RTTVar      time.Duration // round-trip time variance
+	Cwnd        uint32        // congestion window in segments
+	Retransmits uint32        // total retransmitted segments
+	PacingRate  uint64        // bytes/s
+}
+
+// tcpInfoFunc samples TCP_INFO from a connection.
+// ok is false when the sample could not be taken.
+type tcpInfoFunc func() (info TCPInfo, ok bool)
+
+// tcpConnOf finds the *net.TCPConn beneath conn, if any.
+func tcpConnOf(conn net.Conn) (*net.TCPConn, bool) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	tcpConn, ok := conn.(*net.TCPConn)
+	return tcpConn, ok
+}
+
+// tcpInfoSampler returns a TCP_INFO sampler for conn,
+// or nil if conn is not TCP or the platform does not support TCP_INFO.
+func tcpInfoSampler(conn net.Conn) tcpInfoFunc {
+	tcpConn, ok := tcpConnOf(conn)
+	if !ok {
+		return nil
+	}
+	return platformTCPInfoSampler(tcpConn)
+}
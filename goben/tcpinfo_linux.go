@@ -0,0 +1,34 @@
+//go:build linux
+
+package goben
+
+import (
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func platformTCPInfoSampler(conn *net.TCPConn) tcpInfoFunc {
+	raw, errRaw := conn.SyscallConn()
+	if errRaw != nil {
+		return nil
+	}
+	return func() (TCPInfo, bool) {
+		var ti *unix.TCPInfo
+		var errOpt error
+		errControl := raw.Control(func(fd uintptr) {
+			ti, errOpt = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+		})
+		if errControl != nil || errOpt != nil {
+			return TCPInfo{}, false
+		}
+		return TCPInfo{
+			RTT:         time.Duration(ti.Rtt) * time.Microsecond,
+			RTTVar:      time.Duration(ti.Rttvar) * time.Microsecond,
+			Cwnd:        ti.Snd_cwnd,
+			Retransmits: ti.Total_retrans,
+			PacingRate:  ti.Pacing_rate,
+		}, true
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package goben
+
+import "net"
+
+// TCP_INFO sampling is only supported on linux.
+func platformTCPInfoSampler(_ *net.TCPConn) tcpInfoFunc {
+	return nil
+}
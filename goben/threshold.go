@@ -0,0 +1,215 @@
+package goben
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ThresholdCheck is the outcome of one --minMbps, --maxLoss or --maxRTT assertion.
+type ThresholdCheck struct {
+	Name   string // e.g. "send rate"
+	Result string // measured value against the limit, e.g. "940.12 Mbps >= 900.00 Mbps"
+	Pass   bool
+}
+
+// Passed reports whether all thresholds passed, true without thresholds.
+func (s ClientStats) Passed() bool {
+	for _, c := range s.Thresholds {
+		if !c.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// LossPercent returns the percentage of UDP datagrams from the server lost in transit.
+func (s ClientStats) LossPercent() float64 {
+	total := s.ReceivedDatagrams + s.LostDatagrams
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(s.LostDatagrams) / float64(total)
+}
+
+func (app *Config) hasThresholds() bool {
+	return app.MinMbps > 0 || app.maxLoss >= 0 || app.maxRTT > 0
+}
+
+func parseThresholds(app *Config) error {
+	if app.MinMbps < 0 {
+		return fmt.Errorf("bad minMbps: %v: negative rate", app.MinMbps)
+	}
+
+	var maxRTT time.Duration
+	if app.MaxRTT != "" {
+		var err error
+		maxRTT, err = time.ParseDuration(app.MaxRTT)
+		if err != nil {
+			return fmt.Errorf("bad maxRTT: %q: %w", app.MaxRTT, err)
+		}
+	}
+	if maxRTT < 0 {
+		return fmt.Errorf("bad maxRTT: %q: negative duration", app.MaxRTT)
+	}
+	if maxRTT > 0 && app.UDP {
+		return fmt.Errorf("bad maxRTT: %q: RTT is measured from TCP_INFO, not available with --udp", app.MaxRTT)
+	}
+	app.maxRTT = maxRTT
+
+	app.maxLoss = -1
+	app.Opt.SeqNumbers = false
+	if app.MaxLoss == "" {
+		return nil
+	}
+	loss, err := strconv.ParseFloat(strings.TrimSuffix(app.MaxLoss, "%"), 64)
+	if err != nil || loss < 0 || loss > 100 {
+		return fmt.Errorf("bad maxLoss: %q: expected a percentage from 0 to 100", app.MaxLoss)
+	}
+	if !app.UDP || app.Opt.PassiveServer {
+		return fmt.Errorf("bad maxLoss: %q: loss is measured on UDP datagrams from the server, requires --udp without --passiveServer", app.MaxLoss)
+	}
+	if app.Opt.UDPWriteSize < udpSeqSize {
+		return fmt.Errorf("bad maxLoss: %q: udpWriteSize=%d leaves no room for the %d-byte sequence number", app.MaxLoss, app.Opt.UDPWriteSize, udpSeqSize)
+	}
+	app.maxLoss = loss
+	app.Opt.SeqNumbers = true
+	return nil
+}
+
+// checkThresholds evaluates the thresholds against the results of a run.
+// Rates are checked in the directions with traffic, and thresholds
+// that could not be measured fail.
+func (app *Config) checkThresholds(s ClientStats) []ThresholdCheck {
+	var checks []ThresholdCheck
+
+	if app.MinMbps > 0 {
+		u := app.unit.forValue(app.MinMbps)
+		for _, d := range []struct {
+			name   string
+			mbps   float64
+			active bool
+		}{{"send rate", s.WriteMbps, !app.PassiveClient}, {"recv rate", s.ReadMbps, !app.Opt.PassiveServer}} {
+			if !d.active {
+				continue
+			}
+			checks = append(checks, ThresholdCheck{
+				Name:   d.name,
+				Result: fmt.Sprintf("%s >= %s", formatRate(d.mbps, u), formatRate(app.MinMbps, u)),
+				Pass:   d.mbps >= app.MinMbps,
+			})
+		}
+	}
+
+	if app.maxLoss >= 0 {
+		c := ThresholdCheck{Name: "loss"}
+		if s.ReceivedDatagrams == 0 {
+			c.Result = "no datagrams received"
+		} else {
+			c.Result = fmt.Sprintf("%.2f%% <= %.2f%% (%d/%d datagrams lost)", s.LossPercent(), app.maxLoss,
+				s.LostDatagrams, s.ReceivedDatagrams+s.LostDatagrams)
+			c.Pass = s.LossPercent() <= app.maxLoss
+		}
+		checks = append(checks, c)
+	}
+
+	if app.maxRTT > 0 {
+		c := ThresholdCheck{Name: "rtt"}
+		if rtt, ok := meanRTT(s); !ok {
+			c.Result = "not measured (TCP_INFO is linux only)"
+		} else {
+			c.Result = fmt.Sprintf("%v <= %v", rtt, app.maxRTT)
+			c.Pass = rtt <= app.maxRTT
+		}
+		checks = append(checks, c)
+	}
+
+	return checks
+}
+
+// meanRTT averages the smoothed RTT samples of all connections.
+func meanRTT(s ClientStats) (time.Duration, bool) {
+	var sum time.Duration
+	var samples int
+	for _, h := range s.Hosts {
+		for _, c := range h.Connections {
+			for _, data := range []ChartData{c.Read.Intervals, c.Write.Intervals} {
+				for _, ti := range data.TCPInfo {
+					sum += ti.RTT
+					samples++
+				}
+			}
+		}
+	}
+	if samples == 0 {
+		return 0, false
+	}
+	return (sum / time.Duration(samples)).Round(time.Microsecond), true
+}
+
+// logThresholds logs each check, then a PASS or FAIL line.
+func logThresholds(ctx context.Context, checks []ThresholdCheck) {
+	var failed []string
+	for _, c := range checks {
+		status := "ok"
+		if !c.Pass {
+			status = "FAIL"
+			failed = append(failed, c.Name)
+		}
+		summaryf(ctx, "threshold: %s: %s: %s", c.Name, c.Result, status)
+	}
+	if len(failed) > 0 {
+		summaryf(ctx, "FAIL: %s", strings.Join(failed, ", "))
+		return
+	}
+	summaryf(ctx, "PASS")
+}
+
+// lossCounter counts the UDP datagrams received and lost, from their sequence numbers.
+// Datagrams lost after the last one received are not counted.
+type lossCounter struct {
+	received int64
+	next     uint64 // highest sequence number received plus one
+}
+
+func (l *lossCounter) count(p []byte) {
+	if len(p) < udpSeqSize {
+		return
+	}
+	l.received++
+	l.next = max(l.next, binary.BigEndian.Uint64(p[:udpSeqSize])+1)
+}
+
+func (l *lossCounter) lost() int64 {
+	return max(int64(l.next)-l.received, 0) // duplicates could exceed the range
+}
+
+// seqWrite stamps each datagram with a sequence number, see udpSeqSize.
+func seqWrite(f call) call {
+	var seq uint64
+	return func(p []byte) (int, error) {
+		if len(p) < udpSeqSize {
+			return f(p)
+		}
+		binary.BigEndian.PutUint64(p, seq)
+		n, err := f(p)
+		if err == nil {
+			seq++
+		}
+		return n, err
+	}
+}
+
+// lossRead counts received datagrams by sequence number.
+func lossRead(f call, l *lossCounter) call {
+	return func(p []byte) (int, error) {
+		n, err := f(p)
+		if n > 0 {
+			l.count(p[:n])
+		}
+		return n, err
+	}
+}
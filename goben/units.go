@@ -0,0 +1,213 @@
+package goben
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// splitNumber splits "1.5MiB" into 1.5 and "MiB".
+func splitNumber(s string) (float64, string, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsLetter(r) || r == '/' })
+	if i < 0 {
+		i = len(s)
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(s[:i]), 64)
+	if err != nil {
+		return 0, "", err
+	}
+	return v, s[i:], nil
+}
+
+// sizeMultipliers holds decimal (K, KB) and binary (Ki, KiB) size suffixes, lower case.
+var sizeMultipliers = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "ki": 1 << 10, "kib": 1 << 10,
+	"m": 1e6, "mb": 1e6, "mi": 1 << 20, "mib": 1 << 20,
+	"g": 1e9, "gb": 1e9, "gi": 1 << 30, "gib": 1 << 30,
+	"t": 1e12, "tb": 1e12, "ti": 1 << 40, "tib": 1 << 40,
+}
+
+// parseSize parses a byte count with an optional unit suffix:
+// 64K and 64KB are 64000 bytes, 64Ki and 64KiB are 65536 bytes.
+func parseSize(s string) (int64, error) {
+	v, suffix, err := splitNumber(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad size: %q: %w", s, err)
+	}
+	mult, found := sizeMultipliers[strings.ToLower(suffix)]
+	if !found {
+		return 0, fmt.Errorf("bad size: %q: unknown unit %q (expected K, M, G, T, with optional i and B suffixes)", s, suffix)
+	}
+	n := v * mult
+	if n != math.Trunc(n) || math.Abs(n) > math.MaxInt64 {
+		return 0, fmt.Errorf("bad size: %q: not a whole number of bytes", s)
+	}
+	return int64(n), nil
+}
+
+// ratePrefixes holds rate prefixes, lower case, as Mbps per bit/s.
+var ratePrefixes = map[byte]float64{'k': 1e-3, 'm': 1, 'g': 1e3, 't': 1e6}
+
+// parseRate parses a rate into Mbps. A plain number is in Mbps,
+// otherwise units are bits (500M, 2.5Gbps, 100Kbps, 1b/s)
+// or bytes (10MB/s, 10MBps, 1000bytes/s) per second.
+func parseRate(s string) (float64, error) {
+	v, suffix, err := splitNumber(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad rate: %q: %w", s, err)
+	}
+	if suffix == "" {
+		return v, nil
+	}
+	mult := 1e-6 // bit/s
+	if p, found := ratePrefixes[byte(unicode.ToLower(rune(suffix[0])))]; found {
+		mult = p
+		suffix = suffix[1:]
+	}
+	switch suffix {
+	case "", "bps", "b/s", "bit/s":
+	case "Bps", "B/s", "bytes/s":
+		mult *= 8
+	default:
+		return 0, fmt.Errorf("bad rate: %q: unknown unit (expected a number in Mbps, or units like 500M, 2.5Gbps, 100Kbps, 10MB/s)", s)
+	}
+	return v * mult, nil
+}
+
+// rateUnit is a unit for displaying rates, as selected by --unit.
+type rateUnit struct {
+	name string
+	mbps float64 // value of one unit in Mbps, 0 for auto
+}
+
+// rateUnits lists the units accepted by --unit, auto picks a bit rate unit.
+var rateUnits = []rateUnit{
+	{"bps", 1e-6}, {"Kbps", 1e-3}, {"Mbps", 1}, {"Gbps", 1e3}, {"Tbps", 1e6},
+	{"bytes/s", 8e-6}, {"KB/s", 8e-3}, {"MB/s", 8}, {"GB/s", 8e3},
+	{"auto", 0},
+}
+
+var unitMbps = rateUnits[2]
+
+// lookupRateUnit finds a unit by case-insensitive name, empty means Mbps.
+func lookupRateUnit(name string) (rateUnit, error) {
+	if name == "" {
+		return unitMbps, nil
+	}
+	for _, u := range rateUnits {
+		if strings.EqualFold(u.name, name) {
+			return u, nil
+		}
+	}
+	names := make([]string, len(rateUnits))
+	for i, u := range rateUnits {
+		names[i] = u.name
+	}
+	return unitMbps, fmt.Errorf("bad unit: %q (expected %s)", name, strings.Join(names, ", "))
+}
+
+// unitNamed returns the rate unit, falling back to Mbps for unknown names.
+func unitNamed(name string) rateUnit {
+	u, _ := lookupRateUnit(name)
+	return u
+}
+
+// forValue resolves auto to the largest bit rate unit not above mbps.
+func (u rateUnit) forValue(mbps float64) rateUnit {
+	if u.mbps != 0 {
+		return u
+	}
+	if mbps == 0 {
+		return unitMbps
+	}
+	best := rateUnits[0]
+	for _, c := range rateUnits[:5] {
+		if math.Abs(mbps) >= c.mbps {
+			best = c
+		}
+	}
+	return best
+}
+
+// scale converts a rate in Mbps to this unit.
+func (u rateUnit) scale(mbps float64) float64 {
+	return mbps / u.forValue(mbps).mbps
+}
+
+// formatRate formats a rate in Mbps with its unit.
+func formatRate(mbps float64, u rateUnit) string {
+	u = u.forValue(mbps)
+	return fmt.Sprintf("%.2f %s", u.scale(mbps), u.name)
+}
+
+// scaleChart converts the values of a chart from Mbps to unit u.
+func scaleChart(data ChartData, u rateUnit) ChartData {
+	values := make([]float64, len(data.YValues))
+	for i, y := range data.YValues {
+		values[i] = y / u.mbps
+	}
+	data.YValues = values
+	return data
+}
+
+// sizeValue is a pflag.Value for byte counts with unit suffixes, see parseSize.
+type sizeValue[T int | int64] struct {
+	p *T
+}
+
+func newSizeValue[T int | int64](def T, p *T) *sizeValue[T] {
+	*p = def
+	return &sizeValue[T]{p: p}
+}
+
+func (v *sizeValue[T]) String() string { return strconv.FormatInt(int64(*v.p), 10) }
+
+func (v *sizeValue[T]) Set(s string) error {
+	n, err := parseSize(s)
+	if err != nil {
+		return err
+	}
+	if int64(T(n)) != n {
+		return fmt.Errorf("bad size: %q: out of range", s)
+	}
+	*v.p = T(n)
+	return nil
+}
+
+func (v *sizeValue[T]) Type() string { return "size" }
+
+// rateValue is a pflag.Value for rates in Mbps with units, see parseRate.
+type rateValue struct {
+	p *float64
+}
+
+func newRateValue(def float64, p *float64) *rateValue {
+	*p = def
+	return &rateValue{p: p}
+}
+
+func (v *rateValue) String() string { return strconv.FormatFloat(*v.p, 'g', -1, 64) }
+
+func (v *rateValue) Set(s string) error {
+	mbps, err := parseRate(s)
+	if err != nil {
+		return err
+	}
+	*v.p = mbps
+	return nil
+}
+
+func (v *rateValue) Type() string { return "rate" }
+
+func updateUnit(app *Config) error {
+	u, err := lookupRateUnit(app.Unit)
+	if err != nil {
+		return err
+	}
+	app.unit = u
+	return nil
+}
@@ -0,0 +1,171 @@
+package goben
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand/v2"
+)
+
+// Streams verified independently, one per direction.
+const (
+	streamUpload   = 0 // client to server
+	streamDownload = 1 // server to client
+)
+
+// udpSeqSize is the sequence number header stamped on verified UDP datagrams.
+const udpSeqSize = 8
+
+// integrity counts data verification results for one receiver.
+type integrity struct {
+	Bytes            int64 // verified bytes
+	CorruptBytes     int64 // bytes differing from the expected stream
+	Datagrams        int64 // verified UDP datagrams
+	CorruptDatagrams int64 // UDP datagrams with any corrupt or missing byte
+	MissingBytes     int64 // expected bytes that never arrived: truncated datagrams or stream
+}
+
+// randSeed picks a seed for the deterministic payload stream.
+func randSeed() int64 {
+	var b [8]byte
+	_, _ = crand.Read(b[:])
+	return int64(binary.LittleEndian.Uint64(b[:]) &^ (1 << 63))
+}
+
+// newStreamRand returns the pseudo-random generator for a stream.
+// UDP datagrams use one generator per sequence number, since they can be lost or reordered.
+func newStreamRand(seed int64, stream byte, seq uint64) *rand.ChaCha8 {
+	var key [32]byte
+	binary.LittleEndian.PutUint64(key[0:], uint64(seed))
+	key[8] = stream
+	binary.LittleEndian.PutUint64(key[16:], seq)
+	return rand.NewChaCha8(key)
+}
+
+// verifier generates or checks the deterministic stream for one direction of a connection.
+type verifier struct {
+	seed    int64
+	stream  byte
+	udp     bool
+	gen     *rand.ChaCha8 // TCP: continuous stream
+	seq     uint64        // UDP: next datagram to send
+	scratch []byte
+	result  integrity
+
+	// expected by the receiver, see newReceiveVerifier
+	size     int   // UDP: datagram size, 0 means unchecked
+	limit    int64 // bytes in the stream, 0 means unlimited
+	complete bool  // TCP: the stream must reach limit
+}
+
+func newVerifier(seed int64, stream byte, udp bool) *verifier {
+	return &verifier{
+		seed:   seed,
+		stream: stream,
+		udp:    udp,
+		gen:    newStreamRand(seed, stream, 0),
+	}
+}
+
+// newReceiveVerifier returns the verifier of a stream sent with opt:
+// datagrams of UDPWriteSize, the last one cut at the byte limit and,
+// when the test is bounded only by the limit, exactly limit bytes.
+func newReceiveVerifier(opt Options, stream byte, udp bool) *verifier {
+	v := newVerifier(opt.Seed, stream, udp)
+	v.size = opt.UDPWriteSize
+	v.limit = opt.byteLimit(udp)
+	v.complete = opt.TotalDuration == 0
+	return v
+}
+
+// fill writes the next part of the stream into p.
+func (v *verifier) fill(p []byte) {
+	if !v.udp {
+		_, _ = v.gen.Read(p)
+		return
+	}
+	var header [udpSeqSize]byte
+	binary.BigEndian.PutUint64(header[:], v.seq)
+	n := copy(p, header[:])
+	_, _ = newStreamRand(v.seed, v.stream, v.seq).Read(p[n:])
+	v.seq++
+}
+
+// check compares received data against the expected stream.
+func (v *verifier) check(p []byte) {
+	if len(v.scratch) < len(p) {
+		v.scratch = make([]byte, len(p))
+	}
+	expected := v.scratch[:len(p)]
+
+	if !v.udp {
+		_, _ = v.gen.Read(expected)
+		v.result.Bytes += int64(len(p))
+		v.result.CorruptBytes += countDiff(p, expected)
+		return
+	}
+
+	v.result.Datagrams++
+	v.result.Bytes += int64(len(p))
+	if len(p) < udpSeqSize {
+		// truncated before the sequence number
+		v.result.CorruptDatagrams++
+		v.result.CorruptBytes += int64(len(p))
+		v.result.MissingBytes += int64(max(v.size-len(p), 0))
+		return
+	}
+	seq := binary.BigEndian.Uint64(p[:udpSeqSize])
+	_, _ = newStreamRand(v.seed, v.stream, seq).Read(expected[udpSeqSize:])
+	bad := countDiff(p[udpSeqSize:], expected[udpSeqSize:])
+	missing := max(v.datagramSize(seq)-int64(len(p)), 0)
+	if bad > 0 || missing > 0 {
+		v.result.CorruptDatagrams++
+		v.result.CorruptBytes += bad
+		v.result.MissingBytes += missing
+	}
+}
+
+// datagramSize returns the size of datagram seq as sent, or 0 if unknown.
+func (v *verifier) datagramSize(seq uint64) int64 {
+	size := int64(v.size)
+	if v.limit > 0 {
+		size = min(size, max(v.limit-int64(seq)*int64(v.size), 0))
+	}
+	return size
+}
+
+// finish counts the bytes missing from a TCP stream that ended short of its limit.
+// A stream without any data is not checked, since passive peers send nothing.
+func (v *verifier) finish() {
+	if !v.udp && v.complete && v.result.Bytes > 0 && v.limit > v.result.Bytes {
+		v.result.MissingBytes += v.limit - v.result.Bytes
+	}
+}
+
+func countDiff(a, b []byte) int64 {
+	var bad int64
+	for i := range a {
+		if a[i] != b[i] {
+			bad++
+		}
+	}
+	return bad
+}
+
+// verifyWrite fills each buffer from the deterministic stream before sending it.
+func verifyWrite(f call, v *verifier) call {
+	return func(p []byte) (int, error) {
+		v.fill(p)
+		return f(p)
+	}
+}
+
+// verifyRead checks received data against the deterministic stream.
+func verifyRead(f call, v *verifier) call {
+	return func(p []byte) (int, error) {
+		n, err := f(p)
+		if n > 0 {
+			v.check(p[:n])
+		}
+		return n, err
+	}
+}